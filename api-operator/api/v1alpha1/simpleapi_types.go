@@ -17,27 +17,40 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"encoding/json"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
 // SimpleapiSpec defines the desired state of Simpleapi
+// +kubebuilder:validation:XValidation:rule="!has(self.rbacRules) || size(self.rbacRules) == 0 || (has(self.serviceAccountSpec) ? (has(self.serviceAccountSpec.name) ? self.serviceAccountSpec.name != 'default' : has(self.serviceAccountSpec.create) && self.serviceAccountSpec.create) : has(self.serviceAccount) && self.serviceAccount != 'default')",message="rbacRules need a dedicated ServiceAccount, set serviceAccountSpec.create or serviceAccountSpec.name"
 type SimpleapiSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
+	// Version is set as the version label of every generated object, so it has to be a valid
+	// label value: build metadata such as 1.2.3+build is not allowed
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`
+	Version string `json:"version"`
+
 	// Foo is an example field of Simpleapi. Edit simpleapi_types.go to remove/update
 	Image                 string                      `json:"image"`
-	Version               string                      `json:"version"`
 	Port                  int32                       `json:"port"`
 	Replicas              *int32                      `json:"replicas"`
-	IngressType           string                      `json:"ingressType"                     example:"httproute or ingress"` // httproute or ingress
+	IngressType           string                      `json:"ingressType"                     example:"httproute, grpcroute, tcproute, tlsroute or ingress"` // httproute, grpcroute, tcproute, tlsroute or ingress
 	EnvoyGateway          string                      `json:"envoyGateway,omitempty"`
 	EnvoyGatewayNamespace string                      `json:"envoyGatewayNamespace,omitempty"`
-	ServiceAccountName    string                      `json:"serviceAccount"`
+	ServiceAccountName    string                      `json:"serviceAccount,omitempty"` // Deprecated: use serviceAccountSpec, the named account is still created
 	ImagePullPolicy       corev1.PullPolicy           `json:"imagepullPolicy,omitempty"`
 	Resources             corev1.ResourceRequirements `json:"resources"`
 	PodSecurityContext    *corev1.PodSecurityContext  `json:"podSecurityContext"`
@@ -46,14 +59,519 @@ type SimpleapiSpec struct {
 	Affinity              *corev1.Affinity            `json:"affinity"`
 	Tolerations           []corev1.Toleration         `json:"tolerations"`
 	IngressHostName       string                      `json:"ingressHostName,omitempty"`
+	Mirror                *MirrorSpec                 `json:"mirror,omitempty"`
+
+	// ServiceAccountSpec selects the ServiceAccount the API pods run as. The block is named
+	// serviceAccountSpec because serviceAccount already holds the deprecated account name,
+	// a serviceAccount block is not valid and is rejected by the API server.
+	// +optional
+	ServiceAccountSpec *ServiceAccountSpec `json:"serviceAccountSpec,omitempty"`
+
+	// ParentRefs lists the Gateways and listeners the HTTPRoute attaches to,
+	// when empty envoyGateway and envoyGatewayNamespace are used
+	// +optional
+	ParentRefs []ParentRef `json:"parentRefs,omitempty"`
+
+	// RBACRules are granted to the API ServiceAccount through a namespaced Role and RoleBinding
+	// managed by the operator, both are removed when the list is empty. A dedicated ServiceAccount
+	// is required and the user creating the Simpleapi must hold the rules.
+	// +optional
+	RBACRules []rbacv1.PolicyRule `json:"rbacRules,omitempty"`
+
+	// NetworkPolicy generates a NetworkPolicy for every version of the API
+	// +optional
+	NetworkPolicy *NetworkPolicySpec `json:"networkPolicy,omitempty"`
+
+	// Monitoring generates a Prometheus Operator ServiceMonitor selecting every version Service
+	// +optional
+	Monitoring *MonitoringSpec `json:"monitoring,omitempty"`
+
+	// Strategy selects how versions are exposed. pathPerVersion routes /api/<version> to each
+	// retained version, blueGreen routes /api to the active version and the new version to a
+	// preview path or hostname until it is promoted
+	// +kubebuilder:validation:Enum=pathPerVersion;blueGreen
+	// +kubebuilder:default=pathPerVersion
+	// +optional
+	Strategy string `json:"strategy,omitempty"`
+
+	// BlueGreen configures the blueGreen strategy
+	// +optional
+	BlueGreen *BlueGreenSpec `json:"blueGreen,omitempty"`
+
+	// Hooks are Jobs run around the rollout of a new version
+	// +optional
+	Hooks *HooksSpec `json:"hooks,omitempty"`
+
+	// MinReadyReplicas a new version needs, together with the Deployment Available condition,
+	// before it joins the routes, defaults to 1
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MinReadyReplicas *int32 `json:"minReadyReplicas,omitempty"`
+
+	// DrainSeconds a pruned version keeps running after it was removed from the routes,
+	// so in-flight and keep-alive connections can finish, defaults to 30
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	DrainSeconds *int32 `json:"drainSeconds,omitempty"`
+
+	// PreStop is run in the API container before it is stopped
+	// +optional
+	PreStop *corev1.LifecycleHandler `json:"preStop,omitempty"`
+
+	// TerminationGracePeriodSeconds of the API pods
+	// +optional
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+
+	// VersionPolicy controls how versions are ordered and which ones are retained
+	// +optional
+	VersionPolicy *VersionPolicySpec `json:"versionPolicy,omitempty"`
+
+	// Versions holds the settings of individual versions
+	// +listType=map
+	// +listMapKey=version
+	// +optional
+	Versions []VersionSpec `json:"versions,omitempty"`
+
+	// Rollout settings of the version Deployments, spec.versions[].rollout overrides them per field
+	// +optional
+	Rollout *RolloutSpec `json:"rollout,omitempty"`
+
+	// NodeSelector of the API pods
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// TopologySpreadConstraints of the API pods
+	// +optional
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+
+	// ZoneSpread adds a topology spread constraint that spreads the pods of each version across
+	// topology.kubernetes.io/zone with a max skew of 1, pods stay pending rather than break the spread
+	// +optional
+	ZoneSpread bool `json:"zoneSpread,omitempty"`
+
+	// PriorityClassName of the API pods
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// RuntimeClassName of the API pods
+	// +optional
+	RuntimeClassName *string `json:"runtimeClassName,omitempty"`
+
+	// HostAliases added to the hosts file of the API pods
+	// +optional
+	HostAliases []corev1.HostAlias `json:"hostAliases,omitempty"`
+
+	// DNSConfig of the API pods
+	// +optional
+	DNSConfig *corev1.PodDNSConfig `json:"dnsConfig,omitempty"`
+
+	// ContainerSecurityContext of the API container
+	// +optional
+	ContainerSecurityContext *corev1.SecurityContext `json:"containerSecurityContext,omitempty"`
+
+	// SecurityProfile restricted fills the unset pod and container security context fields with
+	// values that meet the restricted Pod Security Standard, and refuses to roll out pods that the
+	// pod-security.kubernetes.io/enforce level of the namespace would reject
+	// +kubebuilder:validation:Enum=none;restricted
+	// +kubebuilder:default=none
+	// +optional
+	SecurityProfile string `json:"securityProfile,omitempty"`
+
+	// InitContainers run before the API container, an init container with restartPolicy Always
+	// is a native sidecar that keeps running next to it. The {version} placeholder in an image
+	// is replaced with the version of the Deployment.
+	// +optional
+	InitContainers []ExtraContainer `json:"initContainers,omitempty"`
+
+	// Sidecars run next to the API container in every version, such as an auth proxy or a log
+	// shipper. The {version} placeholder in an image is replaced with the version of the Deployment.
+	// +optional
+	Sidecars []ExtraContainer `json:"sidecars,omitempty"`
+
+	// PodTemplatePatch is a strategic merge patch applied to the generated pod template of every
+	// version, for PodSpec fields the Simpleapi does not model. The app, version and
+	// apps.api.test/simpleapi labels must not be changed.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Type=object
+	// +optional
+	PodTemplatePatch *runtime.RawExtension `json:"podTemplatePatch,omitempty"`
+
+	// Ports of the API container, they replace port when set. The routes target the port named
+	// http, or else the first port with an appProtocol, the other ports such as metrics or admin
+	// are only exposed on the version Service.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Ports []PortSpec `json:"ports,omitempty"`
+
+	// GRPC configures how the GRPCRoute of ingressType grpcroute tells the versions apart
+	// +optional
+	GRPC *GRPCSpec `json:"grpc,omitempty"`
+}
+
+// GRPCSpec selects the version of a gRPC request by its service name or by a request header
+// +kubebuilder:validation:XValidation:rule="self.match != 'service' || has(self.service)",message="service is required with match service"
+type GRPCSpec struct {
+	// Match on the gRPC service name or on the version header
+	// +kubebuilder:validation:Enum=service;header
+	// +kubebuilder:default=header
+	// +optional
+	Match string `json:"match,omitempty"`
+	// Service is the fully qualified gRPC service of match service, {version} is replaced
+	// with the version, for example orders.{version}.OrderService
+	// +optional
+	Service string `json:"service,omitempty"`
+	// Header that carries the version with match header, defaults to x-api-version
+	// +optional
+	Header string `json:"header,omitempty"`
+}
+
+// PortSpec is a named container port and the Service port in front of it
+type PortSpec struct {
+	// Name of the container and Service port
+	// +kubebuilder:validation:MaxLength=15
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+	// ContainerPort the API listens on
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	ContainerPort int32 `json:"containerPort"`
+	// ServicePort exposed by the version Service, defaults to containerPort
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	ServicePort *int32 `json:"servicePort,omitempty"`
+	// Protocol of the port
+	// +kubebuilder:validation:Enum=TCP;UDP;SCTP
+	// +kubebuilder:default=TCP
+	// +optional
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+	// AppProtocol tells the Gateway or Ingress controller how to talk to the port
+	// +kubebuilder:validation:Enum=http;h2c;grpc
+	// +optional
+	AppProtocol string `json:"appProtocol,omitempty"`
+}
+
+// RolloutSpec holds the Deployment rollout settings. ProgressDeadlineSeconds is also how long the
+// operator waits before it reports a rollout as failed with the Degraded condition.
+type RolloutSpec struct {
+	// Strategy of the Deployment, maxSurge and maxUnavailable apply when an existing version is
+	// updated in place
+	// +optional
+	Strategy *appsv1.DeploymentStrategy `json:"strategy,omitempty"`
+	// MinReadySeconds a new pod must be ready before it counts as available
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinReadySeconds *int32 `json:"minReadySeconds,omitempty"`
+	// ProgressDeadlineSeconds after which a rollout that makes no progress has failed
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty"`
+	// RevisionHistoryLimit of old ReplicaSets kept for rollbacks
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+}
+
+// VersionSpec schedules the deprecation and retirement of one version and holds its rollout settings
+type VersionSpec struct {
+	// Version the settings apply to, as used in spec.version
+	Version string `json:"version"`
+	// DeprecatedAt adds the Deprecation response header, and the Sunset header when sunsetAt
+	// is set, to the routes of the version from this time
+	// +optional
+	DeprecatedAt *metav1.Time `json:"deprecatedAt,omitempty"`
+	// SunsetAt removes the version from the routes and prunes it from this time,
+	// the version in spec.version is never pruned
+	// +optional
+	SunsetAt *metav1.Time `json:"sunsetAt,omitempty"`
+	// Rollout overrides the global rollout settings for this version
+	// +optional
+	Rollout *RolloutSpec `json:"rollout,omitempty"`
+}
+
+// VersionPolicySpec orders versions by rollout time or by semantic version and selects the
+// versions that stay routed
+type VersionPolicySpec struct {
+	// Ordering of the versions, deployedAt orders by the time a version was rolled out and
+	// semver by the parsed version such as v1, v1.2 or v1.2.3
+	// +kubebuilder:validation:Enum=deployedAt;semver
+	// +kubebuilder:default=deployedAt
+	// +optional
+	Ordering string `json:"ordering,omitempty"`
+	// KeepMajors retains the newest version of each of the last N major versions and routes
+	// it on /api/v<major>, so minor and patch releases replace each other. It implies the semver
+	// ordering, versions that do not parse are pruned. The newest two versions are kept when unset.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	KeepMajors *int32 `json:"keepMajors,omitempty"`
+}
+
+// ExtraContainer is an init container or sidecar added to the pod template. Its schema is not
+// expanded in the CRD, which would otherwise grow past the size kubectl apply and etcd accept,
+// the API server validates the Deployments it ends up in.
+// +kubebuilder:validation:Type=object
+// +kubebuilder:pruning:PreserveUnknownFields
+type ExtraContainer struct {
+	corev1.Container `json:",inline"`
+}
+
+// MarshalJSON encodes the container as a plain corev1.Container, it also makes controller-gen
+// use the markers above instead of the Container schema
+func (c ExtraContainer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.Container)
+}
+
+// UnmarshalJSON decodes a plain corev1.Container
+func (c *ExtraContainer) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &c.Container)
+}
+
+// HooksSpec holds the Job templates run for every new version. A failed hook blocks the
+// rollout until its Job is deleted, which runs it again, or the version is changed.
+// The templates are kept schemaless in the CRD, which would otherwise grow past the size
+// kubectl apply and etcd accept, the API server validates the Jobs created from them.
+type HooksSpec struct {
+	// PreRollout must succeed before the Deployment of the new version is created,
+	// for example to run database migrations
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	PreRollout *batchv1.JobTemplateSpec `json:"preRollout,omitempty"`
+	// PostRollout runs once the new version is available and must succeed before it is routed
+	// or promoted, for example smoke tests against SIMPLEAPI_SERVICE_URL
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	PostRollout *batchv1.JobTemplateSpec `json:"postRollout,omitempty"`
+}
+
+// BlueGreenSpec configures the preview route and the promotion of the blueGreen strategy.
+// The preview version is promoted when the apps.api.test/promote annotation or
+// status.blueGreen.approvedVersion is set to it.
+type BlueGreenSpec struct {
+	// PreviewHostName exposes the preview version on its own hostname
+	// +optional
+	PreviewHostName string `json:"previewHostName,omitempty"`
+	// PreviewPath is the path prefix of the preview version, defaults to /preview/api
+	// +optional
+	PreviewPath string `json:"previewPath,omitempty"`
+	// ScaleDownDelaySeconds keeps the previously active version after a promotion, defaults to 300
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ScaleDownDelaySeconds *int32 `json:"scaleDownDelaySeconds,omitempty"`
+}
+
+// MonitoringSpec configures the ServiceMonitor scraping the application metrics,
+// it is skipped when the monitoring.coreos.com CRDs are not installed
+type MonitoringSpec struct {
+	Enabled bool `json:"enabled"`
+	// Path of the metrics endpoint, defaults to /metrics
+	// +optional
+	Path string `json:"path,omitempty"`
+	// Port is the name of the Service port serving metrics, defaults to http
+	// +optional
+	Port string `json:"port,omitempty"`
+	// Namespace Prometheus scrapes from, the NetworkPolicy admits it to the metrics port,
+	// defaults to monitoring
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Interval between scrapes, for example 30s
+	// +kubebuilder:validation:Pattern=`^(0|(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?)$`
+	// +optional
+	Interval string `json:"interval,omitempty"`
+	// Relabelings applied to the scraped targets
+	// +optional
+	Relabelings []RelabelConfig `json:"relabelings,omitempty"`
+}
+
+// RelabelConfig is a Prometheus relabeling rule
+type RelabelConfig struct {
+	// +optional
+	SourceLabels []string `json:"sourceLabels,omitempty"`
+	// +optional
+	Separator string `json:"separator,omitempty"`
+	// +optional
+	TargetLabel string `json:"targetLabel,omitempty"`
+	// +optional
+	Regex string `json:"regex,omitempty"`
+	// +optional
+	Modulus uint64 `json:"modulus,omitempty"`
+	// +optional
+	Replacement *string `json:"replacement,omitempty"`
+	// +kubebuilder:validation:Enum=replace;keep;drop;hashmod;labelmap;labeldrop;labelkeep;lowercase;uppercase;keepequal;dropequal
+	// +optional
+	Action string `json:"action,omitempty"`
+}
+
+// NetworkPolicySpec allows traffic to the API port only from the gateway or ingress controller
+// namespaces, and optionally restricts egress to an allowlist
+type NetworkPolicySpec struct {
+	Enabled bool `json:"enabled"`
+	// IngressNamespaces allowed to reach the API port, defaults to the parentRefs namespaces
+	// for httproute and to ingress-nginx for ingress
+	// +optional
+	IngressNamespaces []string `json:"ingressNamespaces,omitempty"`
+	// Egress allowlist for the API pods, DNS to kube-system is always added.
+	// Egress is not restricted when empty
+	// +optional
+	Egress []networkingv1.NetworkPolicyEgressRule `json:"egress,omitempty"`
+}
+
+// ServiceAccountSpec selects how the ServiceAccount of the API pods is managed:
+// created and owned by the operator, an existing account, or none for the namespace default.
+// It is set as spec.serviceAccountSpec, spec.serviceAccount keeps the deprecated string form.
+type ServiceAccountSpec struct {
+	// Create makes the operator create and own the ServiceAccount
+	// +optional
+	Create bool `json:"create,omitempty"`
+	// Name of the ServiceAccount, defaults to the Simpleapi name when create is true
+	// and to the namespace default account otherwise
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Annotations set on a created ServiceAccount, for example for workload identity
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// AutomountToken mounts the ServiceAccount token into the pods, defaults to false
+	// +optional
+	AutomountToken *bool `json:"automountToken,omitempty"`
+}
+
+// ParentRef selects a Gateway, and optionally one of its listeners, for the HTTPRoute
+type ParentRef struct {
+	Name string `json:"name"`
+	// Namespace of the Gateway, defaults to the Simpleapi namespace
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// SectionName is the listener name on the Gateway
+	// +optional
+	SectionName string `json:"sectionName,omitempty"`
+	// Port is the listener port on the Gateway
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port *int32 `json:"port,omitempty"`
+}
+
+// MirrorSpec configures shadowing of the stable route traffic to the newest version.
+// It is only honoured for ingressType httproute.
+type MirrorSpec struct {
+	Enabled bool `json:"enabled"`
+	// Percent of the stable requests that are mirrored, leave empty when the
+	// Gateway implementation does not support mirror percentages.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	Percent *int32 `json:"percent,omitempty"`
 }
 
 // SimpleapiStatus defines the observed state of Simpleapi
 type SimpleapiStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// MirroredVersion is the version currently receiving shadow traffic from the stable route
+	MirroredVersion string `json:"mirroredVersion,omitempty"`
+
+	// BlueGreen is the promotion state of the blueGreen strategy
+	// +optional
+	BlueGreen *BlueGreenStatus `json:"blueGreen,omitempty"`
+
+	// Hooks are the outcomes of the hook Jobs of the current version
+	// +optional
+	Hooks []HookStatus `json:"hooks,omitempty"`
+
+	// URLs are the effective external URLs of every routed path
+	// +optional
+	URLs []VersionURL `json:"urls,omitempty"`
+
+	// Conditions represent the latest observations of the Simpleapi state
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// BlueGreenStatus tracks the active and preview versions of the blueGreen strategy
+type BlueGreenStatus struct {
+	// ActiveVersion receives the stable traffic
+	ActiveVersion string `json:"activeVersion,omitempty"`
+	// PreviewVersion is deployed next to the active version and waits for promotion
+	// +optional
+	PreviewVersion string `json:"previewVersion,omitempty"`
+	// ApprovedVersion can be patched on the status subresource to promote the preview version
+	// +optional
+	ApprovedVersion string `json:"approvedVersion,omitempty"`
+	// PreviousVersion was active before the last promotion, it is removed after the scale down delay
+	// +optional
+	PreviousVersion string `json:"previousVersion,omitempty"`
+	// PromotedAt is the time of the last promotion
+	// +optional
+	PromotedAt *metav1.Time `json:"promotedAt,omitempty"`
+}
+
+// HookStatus is the outcome of one hook Job
+type HookStatus struct {
+	// Hook is preRollout or postRollout
+	Hook    string `json:"hook"`
+	Version string `json:"version"`
+	Job     string `json:"job,omitempty"`
+	// Phase is Waiting, Running, Succeeded or Failed
+	Phase string `json:"phase"`
+	// Logs is the command to read the logs of the hook Job
+	// +optional
+	Logs string `json:"logs,omitempty"`
 }
 
+// VersionURL is the effective external URL of one routed version
+type VersionURL struct {
+	Version string `json:"version"`
+	URL     string `json:"url"`
+}
+
+// Condition types reported on the Simpleapi status
+const (
+	// ConditionPaused is true while the apps.api.test/paused annotation stops all mutations
+	ConditionPaused = "Paused"
+	// ConditionServiceAccountReady reports whether the configured ServiceAccount can be used
+	ConditionServiceAccountReady = "ServiceAccountReady"
+	// ConditionRBACReady reports whether spec.rbacRules are granted through the managed Role and RoleBinding
+	ConditionRBACReady = "RBACReady"
+	// ConditionServiceMonitorReady reports whether the ServiceMonitor could be created
+	ConditionServiceMonitorReady = "ServiceMonitorReady"
+	// ConditionVersionAvailable reports whether the spec version is available and routed
+	ConditionVersionAvailable = "VersionAvailable"
+	// ConditionDegraded is true when the rollout of the spec version exceeded its progress deadline
+	ConditionDegraded = "Degraded"
+	// ConditionPodSecurityAdmitted reports whether the pods meet the Pod Security level of the namespace
+	ConditionPodSecurityAdmitted = "PodSecurityAdmitted"
+	// ConditionPodTemplateValid reports whether the podTemplatePatch applies to the pod template
+	ConditionPodTemplateValid = "PodTemplateValid"
+	// ConditionVersionNameUnique reports whether the spec version maps to Deployment and Service
+	// names no other retained version uses
+	ConditionVersionNameUnique = "VersionNameUnique"
+	// ConditionHooksSucceeded reports whether the hook Jobs of the current version succeeded
+	ConditionHooksSucceeded = "HooksSucceeded"
+	// ConditionDeprecationApplied reports whether the Ingresses adding the deprecation headers
+	// to deprecated versions were applied, a version whose Ingress failed is routed without them
+	ConditionDeprecationApplied = "DeprecationApplied"
+	// ConditionPruneSucceeded reports whether the versions that are no longer retained were pruned
+	ConditionPruneSucceeded = "PruneSucceeded"
+	// ConditionRouteSupported reports whether the CRDs needed for the ingressType are installed
+	ConditionRouteSupported = "RouteSupported"
+	// ConditionRouteAllowed reports whether the parent Gateways allow the HTTPRoute namespace
+	ConditionRouteAllowed = "RouteAllowed"
+	// ConditionRouteAccepted reports whether the Gateway accepted the HTTPRoute
+	// or the Ingress got a load balancer address
+	ConditionRouteAccepted = "RouteAccepted"
+	// ConditionRouteResolvedRefs reports whether the Gateway resolved all HTTPRoute backends
+	ConditionRouteResolvedRefs = "RouteResolvedRefs"
+	// ConditionReady is true once the route is accepted and the version URLs are published
+	ConditionReady = "Ready"
+)
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 
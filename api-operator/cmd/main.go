@@ -37,7 +37,9 @@ import (
 
 	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
 	"github.com/dkr290/simple-operator/api-operator/internal/controller"
+	webhookappsv1alpha1 "github.com/dkr290/simple-operator/api-operator/internal/webhook/v1alpha1"
 	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -51,6 +53,7 @@ func init() {
 
 	utilruntime.Must(appsv1alpha1.AddToScheme(scheme))
 	utilruntime.Must(gatewayv1.Install(scheme))
+	utilruntime.Must(gatewayv1alpha2.Install(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -160,13 +163,36 @@ func main() {
 		os.Exit(1)
 	}
 
+	apis, err := controller.DiscoverAPIs(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to discover optional APIs")
+		os.Exit(1)
+	}
+	setupLog.Info(
+		"discovered optional APIs",
+		"httproute", apis.HTTPRoute,
+		"grpcroute", apis.GRPCRoute,
+		"tcproute", apis.TCPRoute,
+		"tlsroute", apis.TLSRoute,
+		"servicemonitor", apis.ServiceMonitor,
+	)
+
 	if err = (&controller.SimpleapiReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		APIs:           apis,
+		WebhookEnabled: os.Getenv("ENABLE_WEBHOOKS") == "true",
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Simpleapi")
 		os.Exit(1)
 	}
+	// the webhook needs serving certificates, see the [WEBHOOK] sections in config/default
+	if os.Getenv("ENABLE_WEBHOOKS") == "true" {
+		if err = webhookappsv1alpha1.SetupSimpleapiWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Simpleapi")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"time"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// promoteAnnotation promotes the blueGreen preview version when set to its version
+	promoteAnnotation string = "apps.api.test/promote"
+
+	defaultPreviewPath           string = "/preview/api"
+	defaultScaleDownDelaySeconds int32  = 300
+)
+
+// advanceBlueGreen moves the blueGreen state forward: the spec version becomes the preview
+// next to the active version and is promoted once approved. It returns the versions that
+// must be kept and how long until the previously active version can be removed.
+// A version that is not routable yet, for example while its postRollout hook runs,
+// is neither previewed nor promoted.
+func advanceBlueGreen(
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+	versionRoutable bool,
+	now time.Time,
+) ([]string, time.Duration) {
+	if SimpleAPIApp.Status.BlueGreen == nil {
+		SimpleAPIApp.Status.BlueGreen = &appsv1alpha1.BlueGreenStatus{}
+	}
+	state := SimpleAPIApp.Status.BlueGreen
+	version := SimpleAPIApp.Spec.Version
+
+	switch {
+	case state.ActiveVersion == "" && versionRoutable:
+		// first rollout, nothing to compare against
+		state.ActiveVersion = version
+		state.PreviewVersion = ""
+	case version == state.ActiveVersion || !versionRoutable:
+		state.PreviewVersion = ""
+	default:
+		state.PreviewVersion = version
+	}
+
+	if state.PreviewVersion != "" &&
+		(SimpleAPIApp.Annotations[promoteAnnotation] == state.PreviewVersion ||
+			state.ApprovedVersion == state.PreviewVersion) {
+		state.PreviousVersion = state.ActiveVersion
+		state.ActiveVersion = state.PreviewVersion
+		state.PreviewVersion = ""
+		state.PromotedAt = &metav1.Time{Time: now}
+	}
+
+	// the spec version is kept while it is not routable yet
+	retained := []string{version}
+	if state.ActiveVersion != "" && state.ActiveVersion != version {
+		retained = append(retained, state.ActiveVersion)
+	}
+	var requeueAfter time.Duration
+	if state.PreviousVersion != "" {
+		remaining := time.Duration(0)
+		if state.PromotedAt != nil {
+			remaining = state.PromotedAt.Add(scaleDownDelay(SimpleAPIApp)).Sub(now)
+		}
+		if remaining > 0 && state.PreviousVersion != state.ActiveVersion {
+			retained = append(retained, state.PreviousVersion)
+			requeueAfter = remaining
+		} else {
+			state.PreviousVersion = ""
+		}
+	}
+	return retained, requeueAfter
+}
+
+func previewPath(SimpleAPIApp *appsv1alpha1.Simpleapi) string {
+	if SimpleAPIApp.Spec.BlueGreen != nil && SimpleAPIApp.Spec.BlueGreen.PreviewPath != "" {
+		return SimpleAPIApp.Spec.BlueGreen.PreviewPath
+	}
+	return defaultPreviewPath
+}
+
+func scaleDownDelay(SimpleAPIApp *appsv1alpha1.Simpleapi) time.Duration {
+	seconds := defaultScaleDownDelaySeconds
+	if SimpleAPIApp.Spec.BlueGreen != nil && SimpleAPIApp.Spec.BlueGreen.ScaleDownDelaySeconds != nil {
+		seconds = *SimpleAPIApp.Spec.BlueGreen.ScaleDownDelaySeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAdvanceBlueGreen(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	promotedAt := func(ago time.Duration) *metav1.Time {
+		return &metav1.Time{Time: now.Add(-ago)}
+	}
+	tests := []struct {
+		name         string
+		version      string
+		promote      string
+		status       *appsv1alpha1.BlueGreenStatus
+		routable     bool
+		want         appsv1alpha1.BlueGreenStatus
+		wantRetained []string
+		wantRequeue  time.Duration
+	}{
+		{
+			name:         "first rollout becomes active",
+			version:      "v1",
+			routable:     true,
+			want:         appsv1alpha1.BlueGreenStatus{ActiveVersion: "v1"},
+			wantRetained: []string{"v1"},
+		},
+		{
+			name:         "first rollout waits until routable",
+			version:      "v1",
+			want:         appsv1alpha1.BlueGreenStatus{},
+			wantRetained: []string{"v1"},
+		},
+		{
+			name:         "new version is previewed",
+			version:      "v2",
+			status:       &appsv1alpha1.BlueGreenStatus{ActiveVersion: "v1"},
+			routable:     true,
+			want:         appsv1alpha1.BlueGreenStatus{ActiveVersion: "v1", PreviewVersion: "v2"},
+			wantRetained: []string{"v2", "v1"},
+		},
+		{
+			name:         "version not routable is not previewed",
+			version:      "v2",
+			status:       &appsv1alpha1.BlueGreenStatus{ActiveVersion: "v1", PreviewVersion: "v2"},
+			want:         appsv1alpha1.BlueGreenStatus{ActiveVersion: "v1"},
+			wantRetained: []string{"v2", "v1"},
+		},
+		{
+			name:         "version not routable is not promoted",
+			version:      "v2",
+			promote:      "v2",
+			status:       &appsv1alpha1.BlueGreenStatus{ActiveVersion: "v1", PreviewVersion: "v2"},
+			routable:     false,
+			want:         appsv1alpha1.BlueGreenStatus{ActiveVersion: "v1"},
+			wantRetained: []string{"v2", "v1"},
+		},
+		{
+			name:     "promoted by annotation",
+			version:  "v2",
+			promote:  "v2",
+			status:   &appsv1alpha1.BlueGreenStatus{ActiveVersion: "v1", PreviewVersion: "v2"},
+			routable: true,
+			want: appsv1alpha1.BlueGreenStatus{
+				ActiveVersion:   "v2",
+				PreviousVersion: "v1",
+				PromotedAt:      promotedAt(0),
+			},
+			wantRetained: []string{"v2", "v1"},
+			wantRequeue:  time.Duration(defaultScaleDownDelaySeconds) * time.Second,
+		},
+		{
+			name:    "promoted by approvedVersion",
+			version: "v2",
+			status: &appsv1alpha1.BlueGreenStatus{
+				ActiveVersion:   "v1",
+				PreviewVersion:  "v2",
+				ApprovedVersion: "v2",
+			},
+			routable: true,
+			want: appsv1alpha1.BlueGreenStatus{
+				ActiveVersion:   "v2",
+				PreviousVersion: "v1",
+				ApprovedVersion: "v2",
+				PromotedAt:      promotedAt(0),
+			},
+			wantRetained: []string{"v2", "v1"},
+			wantRequeue:  time.Duration(defaultScaleDownDelaySeconds) * time.Second,
+		},
+		{
+			name:         "annotation for another version does not promote",
+			version:      "v2",
+			promote:      "v3",
+			status:       &appsv1alpha1.BlueGreenStatus{ActiveVersion: "v1", PreviewVersion: "v2"},
+			routable:     true,
+			want:         appsv1alpha1.BlueGreenStatus{ActiveVersion: "v1", PreviewVersion: "v2"},
+			wantRetained: []string{"v2", "v1"},
+		},
+		{
+			name:    "previous version kept during the scale down delay",
+			version: "v2",
+			status: &appsv1alpha1.BlueGreenStatus{
+				ActiveVersion:   "v2",
+				PreviousVersion: "v1",
+				PromotedAt:      promotedAt(100 * time.Second),
+			},
+			routable: true,
+			want: appsv1alpha1.BlueGreenStatus{
+				ActiveVersion:   "v2",
+				PreviousVersion: "v1",
+				PromotedAt:      promotedAt(100 * time.Second),
+			},
+			wantRetained: []string{"v2", "v1"},
+			wantRequeue:  time.Duration(defaultScaleDownDelaySeconds)*time.Second - 100*time.Second,
+		},
+		{
+			name:    "previous version released after the scale down delay",
+			version: "v2",
+			status: &appsv1alpha1.BlueGreenStatus{
+				ActiveVersion:   "v2",
+				PreviousVersion: "v1",
+				PromotedAt:      promotedAt(time.Hour),
+			},
+			routable: true,
+			want: appsv1alpha1.BlueGreenStatus{
+				ActiveVersion: "v2",
+				PromotedAt:    promotedAt(time.Hour),
+			},
+			wantRetained: []string{"v2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &appsv1alpha1.Simpleapi{
+				Spec:   appsv1alpha1.SimpleapiSpec{Version: tt.version, Strategy: strategyBlueGreen},
+				Status: appsv1alpha1.SimpleapiStatus{BlueGreen: tt.status},
+			}
+			if tt.promote != "" {
+				app.Annotations = map[string]string{promoteAnnotation: tt.promote}
+			}
+			retained, requeue := advanceBlueGreen(app, tt.routable, now)
+			if !slices.Equal(retained, tt.wantRetained) {
+				t.Errorf("advanceBlueGreen() retained = %v, want %v", retained, tt.wantRetained)
+			}
+			if requeue != tt.wantRequeue {
+				t.Errorf("advanceBlueGreen() requeue = %v, want %v", requeue, tt.wantRequeue)
+			}
+			got := *app.Status.BlueGreen
+			if got.ActiveVersion != tt.want.ActiveVersion ||
+				got.PreviewVersion != tt.want.PreviewVersion ||
+				got.PreviousVersion != tt.want.PreviousVersion ||
+				got.ApprovedVersion != tt.want.ApprovedVersion ||
+				!got.PromotedAt.Equal(tt.want.PromotedAt) {
+				t.Errorf("advanceBlueGreen() status = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,22 +2,30 @@
 package controller
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/utils/ptr"
 )
 
+// versionPlaceholder in the image of an init container or sidecar is replaced with the version
+const versionPlaceholder = "{version}"
+
 func (r *SimpleapiReconciler) constructDeployment(
 	SimpleAPIApp appsv1alpha1.Simpleapi, timestamp int64,
-) *appsv1.Deployment {
+) (*appsv1.Deployment, error) {
 	labels := map[string]string{
-		"app":     SimpleAPIApp.Labels["app"],
-		"version": SimpleAPIApp.Spec.Version,
+		"app":          SimpleAPIApp.Labels["app"],
+		"version":      SimpleAPIApp.Spec.Version,
+		simpleapiLabel: SimpleAPIApp.Name,
 	}
 
 	var podSpec corev1.PodSpec
@@ -28,7 +36,7 @@ func (r *SimpleapiReconciler) constructDeployment(
 
 	objectMetaData := metav1.ObjectMeta{
 		Name: deploymentName(
-			strings.ToLower(SimpleAPIApp.Spec.Version),
+			SimpleAPIApp.Spec.Version,
 			SimpleAPIApp.Name,
 		),
 		Namespace: SimpleAPIApp.Namespace,
@@ -38,10 +46,7 @@ func (r *SimpleapiReconciler) constructDeployment(
 		},
 	}
 
-	serviceAccountName := SimpleAPIApp.Spec.ServiceAccountName
-	if serviceAccountName == "" {
-		serviceAccountName = "default"
-	}
+	saName := serviceAccountName(&SimpleAPIApp)
 
 	imagePullPolicy := SimpleAPIApp.Spec.ImagePullPolicy
 	if imagePullPolicy == "" {
@@ -50,12 +55,16 @@ func (r *SimpleapiReconciler) constructDeployment(
 	imagePullSecret := SimpleAPIApp.Spec.ImagePullSecret
 
 	if imagePullSecret == "" {
-		podSpec = GetPodSpec(SimpleAPIApp, serviceAccountName, false, imagePullPolicy)
+		podSpec = GetPodSpec(SimpleAPIApp, saName, false, imagePullPolicy)
 	} else {
-		podSpec = GetPodSpec(SimpleAPIApp, serviceAccountName, true, imagePullPolicy)
+		podSpec = GetPodSpec(SimpleAPIApp, saName, true, imagePullPolicy)
 	}
+	rollout := rolloutSettings(&SimpleAPIApp, SimpleAPIApp.Spec.Version)
 	specData := appsv1.DeploymentSpec{
-		Replicas: &replicas,
+		Replicas:                &replicas,
+		MinReadySeconds:         ptr.Deref(rollout.MinReadySeconds, 0),
+		ProgressDeadlineSeconds: rollout.ProgressDeadlineSeconds,
+		RevisionHistoryLimit:    rollout.RevisionHistoryLimit,
 		Selector: &metav1.LabelSelector{
 			MatchLabels: labels,
 		},
@@ -68,16 +77,30 @@ func (r *SimpleapiReconciler) constructDeployment(
 		},
 	}
 
+	if rollout.Strategy != nil {
+		specData.Strategy = *rollout.Strategy
+	}
+	if err := applyPodTemplatePatch(&SimpleAPIApp, &specData.Template); err != nil {
+		return nil, err
+	}
+
 	deploy := &appsv1.Deployment{
 		ObjectMeta: objectMetaData,
 		Spec:       specData,
 	}
 
-	return deploy
+	return deploy, nil
 }
 
+// VersionDeployment builds the Deployment of the spec version as the reconciler would create it,
+// the admission webhook dry-runs it to validate the pod template
+func VersionDeployment(SimpleAPIApp *appsv1alpha1.Simpleapi) (*appsv1.Deployment, error) {
+	return (&SimpleapiReconciler{}).constructDeployment(*SimpleAPIApp, time.Now().Unix())
+}
+
+// deploymentName is DNS-safe and leaves room for the -svc suffix of the version Service
 func deploymentName(version string, deploymentName string) string {
-	return fmt.Sprintf(deploymentName+"-%s", strings.ToLower(version))
+	return dnsSafeName(fmt.Sprintf("%s-%s", deploymentName, version), maxNameLength-len("-svc"))
 }
 
 func GetPodSpec(
@@ -88,10 +111,11 @@ func GetPodSpec(
 ) corev1.PodSpec {
 	if isImagePullSecret {
 		return corev1.PodSpec{
-			AutomountServiceAccountToken: ptr.To(false),
+			AutomountServiceAccountToken: automountToken(&SimpleAPIApp),
 			ServiceAccountName:           serviceAccountName,
-			SecurityContext:              SimpleAPIApp.Spec.PodSecurityContext,
-			Containers: []corev1.Container{
+			SecurityContext:              podSecurityContext(&SimpleAPIApp),
+			InitContainers:               extraContainers(&SimpleAPIApp, SimpleAPIApp.Spec.InitContainers),
+			Containers: append([]corev1.Container{
 				{
 					Name: SimpleAPIApp.Name,
 					Image: fmt.Sprintf(
@@ -99,9 +123,7 @@ func GetPodSpec(
 						SimpleAPIApp.Spec.Image,
 						SimpleAPIApp.Spec.Version,
 					),
-					Ports: []corev1.ContainerPort{
-						{ContainerPort: SimpleAPIApp.Spec.Port},
-					},
+					Ports: containerPorts(&SimpleAPIApp),
 					StartupProbe: &corev1.Probe{
 						ProbeHandler: corev1.ProbeHandler{
 							HTTPGet: &corev1.HTTPGetAction{
@@ -115,10 +137,19 @@ func GetPodSpec(
 					},
 					ImagePullPolicy: ImagePullPolicy,
 					Resources:       SimpleAPIApp.Spec.Resources,
+					Lifecycle:       lifecycle(&SimpleAPIApp),
+					SecurityContext: containerSecurityContext(&SimpleAPIApp),
 				},
-			},
-			Affinity:    SimpleAPIApp.Spec.Affinity,
-			Tolerations: SimpleAPIApp.Spec.Tolerations,
+			}, extraContainers(&SimpleAPIApp, SimpleAPIApp.Spec.Sidecars)...),
+			Affinity:                      SimpleAPIApp.Spec.Affinity,
+			Tolerations:                   SimpleAPIApp.Spec.Tolerations,
+			TerminationGracePeriodSeconds: SimpleAPIApp.Spec.TerminationGracePeriodSeconds,
+			NodeSelector:                  SimpleAPIApp.Spec.NodeSelector,
+			TopologySpreadConstraints:     topologySpreadConstraints(&SimpleAPIApp),
+			PriorityClassName:             SimpleAPIApp.Spec.PriorityClassName,
+			RuntimeClassName:              SimpleAPIApp.Spec.RuntimeClassName,
+			HostAliases:                   SimpleAPIApp.Spec.HostAliases,
+			DNSConfig:                     SimpleAPIApp.Spec.DNSConfig,
 
 			ImagePullSecrets: []corev1.LocalObjectReference{
 				{
@@ -128,10 +159,11 @@ func GetPodSpec(
 		}
 	} else {
 		return corev1.PodSpec{
-			AutomountServiceAccountToken: ptr.To(false),
+			AutomountServiceAccountToken: automountToken(&SimpleAPIApp),
 			ServiceAccountName:           serviceAccountName,
-			SecurityContext:              SimpleAPIApp.Spec.PodSecurityContext,
-			Containers: []corev1.Container{
+			SecurityContext:              podSecurityContext(&SimpleAPIApp),
+			InitContainers:               extraContainers(&SimpleAPIApp, SimpleAPIApp.Spec.InitContainers),
+			Containers: append([]corev1.Container{
 				{
 					Name: SimpleAPIApp.Name,
 					Image: fmt.Sprintf(
@@ -139,9 +171,7 @@ func GetPodSpec(
 						SimpleAPIApp.Spec.Image,
 						SimpleAPIApp.Spec.Version,
 					),
-					Ports: []corev1.ContainerPort{
-						{ContainerPort: SimpleAPIApp.Spec.Port},
-					},
+					Ports: containerPorts(&SimpleAPIApp),
 					StartupProbe: &corev1.Probe{
 						ProbeHandler: corev1.ProbeHandler{
 							HTTPGet: &corev1.HTTPGetAction{
@@ -155,10 +185,129 @@ func GetPodSpec(
 					},
 					ImagePullPolicy: ImagePullPolicy,
 					Resources:       SimpleAPIApp.Spec.Resources,
+					Lifecycle:       lifecycle(&SimpleAPIApp),
+					SecurityContext: containerSecurityContext(&SimpleAPIApp),
 				},
+			}, extraContainers(&SimpleAPIApp, SimpleAPIApp.Spec.Sidecars)...),
+			Affinity:                      SimpleAPIApp.Spec.Affinity,
+			Tolerations:                   SimpleAPIApp.Spec.Tolerations,
+			TerminationGracePeriodSeconds: SimpleAPIApp.Spec.TerminationGracePeriodSeconds,
+			NodeSelector:                  SimpleAPIApp.Spec.NodeSelector,
+			TopologySpreadConstraints:     topologySpreadConstraints(&SimpleAPIApp),
+			PriorityClassName:             SimpleAPIApp.Spec.PriorityClassName,
+			RuntimeClassName:              SimpleAPIApp.Spec.RuntimeClassName,
+			HostAliases:                   SimpleAPIApp.Spec.HostAliases,
+			DNSConfig:                     SimpleAPIApp.Spec.DNSConfig,
+		}
+	}
+}
+
+func lifecycle(SimpleAPIApp *appsv1alpha1.Simpleapi) *corev1.Lifecycle {
+	if SimpleAPIApp.Spec.PreStop == nil {
+		return nil
+	}
+	return &corev1.Lifecycle{PreStop: SimpleAPIApp.Spec.PreStop}
+}
+
+// rolloutSettings merges the rollout settings of the version over the global ones
+func rolloutSettings(SimpleAPIApp *appsv1alpha1.Simpleapi, version string) appsv1alpha1.RolloutSpec {
+	rollout := appsv1alpha1.RolloutSpec{}
+	if SimpleAPIApp.Spec.Rollout != nil {
+		rollout = *SimpleAPIApp.Spec.Rollout.DeepCopy()
+	}
+	spec := versionSpec(SimpleAPIApp, version)
+	if spec == nil || spec.Rollout == nil {
+		return rollout
+	}
+	override := spec.Rollout.DeepCopy()
+	if override.Strategy != nil {
+		rollout.Strategy = override.Strategy
+	}
+	if override.MinReadySeconds != nil {
+		rollout.MinReadySeconds = override.MinReadySeconds
+	}
+	if override.ProgressDeadlineSeconds != nil {
+		rollout.ProgressDeadlineSeconds = override.ProgressDeadlineSeconds
+	}
+	if override.RevisionHistoryLimit != nil {
+		rollout.RevisionHistoryLimit = override.RevisionHistoryLimit
+	}
+	return rollout
+}
+
+// topologySpreadConstraints adds the zoneSpread constraint on the app and version labels unless
+// a constraint on the zone key is set explicitly
+func topologySpreadConstraints(SimpleAPIApp *appsv1alpha1.Simpleapi) []corev1.TopologySpreadConstraint {
+	constraints := append([]corev1.TopologySpreadConstraint{}, SimpleAPIApp.Spec.TopologySpreadConstraints...)
+	if !SimpleAPIApp.Spec.ZoneSpread {
+		return constraints
+	}
+	for _, c := range constraints {
+		if c.TopologyKey == corev1.LabelTopologyZone {
+			return constraints
+		}
+	}
+	return append(constraints, corev1.TopologySpreadConstraint{
+		MaxSkew:           1,
+		TopologyKey:       corev1.LabelTopologyZone,
+		WhenUnsatisfiable: corev1.DoNotSchedule,
+		LabelSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"app":     SimpleAPIApp.Labels["app"],
+				"version": SimpleAPIApp.Spec.Version,
 			},
-			Affinity:    SimpleAPIApp.Spec.Affinity,
-			Tolerations: SimpleAPIApp.Spec.Tolerations,
+		},
+	})
+}
+
+// extraContainers copies the init containers or sidecars with the version in their image tag
+// and, with the restricted profile, the restricted security context defaults
+func extraContainers(SimpleAPIApp *appsv1alpha1.Simpleapi, containers []appsv1alpha1.ExtraContainer) []corev1.Container {
+	if len(containers) == 0 {
+		return nil
+	}
+	versioned := make([]corev1.Container, len(containers))
+	for i := range containers {
+		c := *containers[i].Container.DeepCopy()
+		c.Image = strings.ReplaceAll(c.Image, versionPlaceholder, SimpleAPIApp.Spec.Version)
+		if restrictedProfile(SimpleAPIApp) {
+			c.SecurityContext = restrictedSecurityContext(c.SecurityContext)
+		}
+		versioned[i] = c
+	}
+	return versioned
+}
+
+// applyPodTemplatePatch applies spec.podTemplatePatch to the pod template, unknown fields and
+// changes to the labels the selector and Services depend on are rejected
+func applyPodTemplatePatch(SimpleAPIApp *appsv1alpha1.Simpleapi, template *corev1.PodTemplateSpec) error {
+	if SimpleAPIApp.Spec.PodTemplatePatch == nil || len(SimpleAPIApp.Spec.PodTemplatePatch.Raw) == 0 {
+		return nil
+	}
+	original, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+	patched, err := strategicpatch.StrategicMergePatch(
+		original,
+		SimpleAPIApp.Spec.PodTemplatePatch.Raw,
+		corev1.PodTemplateSpec{},
+	)
+	if err != nil {
+		return fmt.Errorf("podTemplatePatch does not apply: %w", err)
+	}
+
+	result := corev1.PodTemplateSpec{}
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&result); err != nil {
+		return fmt.Errorf("podTemplatePatch is not a valid pod template: %w", err)
+	}
+	for k, v := range template.Labels {
+		if result.Labels[k] != v {
+			return fmt.Errorf("podTemplatePatch must not change the %s label", k)
 		}
 	}
+	*template = result
+	return nil
 }
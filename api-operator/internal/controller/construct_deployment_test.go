@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"testing"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestApplyPodTemplatePatch(t *testing.T) {
+	template := func() *corev1.PodTemplateSpec {
+		return &corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{"app": "demo", "version": "v1", simpleapiLabel: "demo"},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "demo", Image: "demo:v1"}},
+			},
+		}
+	}
+	tests := []struct {
+		name    string
+		patch   string
+		check   func(t *testing.T, template *corev1.PodTemplateSpec)
+		wantErr bool
+	}{
+		{
+			name: "no patch",
+			check: func(t *testing.T, template *corev1.PodTemplateSpec) {
+				if len(template.Spec.Containers) != 1 || template.Spec.NodeSelector != nil {
+					t.Errorf("template changed without a patch: %+v", template.Spec)
+				}
+			},
+		},
+		{
+			name:  "pod fields and annotations added",
+			patch: `{"metadata":{"annotations":{"team":"a"}},"spec":{"nodeSelector":{"disk":"ssd"}}}`,
+			check: func(t *testing.T, template *corev1.PodTemplateSpec) {
+				if template.Annotations["team"] != "a" || template.Spec.NodeSelector["disk"] != "ssd" {
+					t.Errorf("patch not applied: %+v", template)
+				}
+			},
+		},
+		{
+			name:  "containers merged by name",
+			patch: `{"spec":{"containers":[{"name":"demo","env":[{"name":"MODE","value":"x"}]}]}}`,
+			check: func(t *testing.T, template *corev1.PodTemplateSpec) {
+				c := template.Spec.Containers
+				if len(c) != 1 || c[0].Image != "demo:v1" || len(c[0].Env) != 1 || c[0].Env[0].Value != "x" {
+					t.Errorf("container not merged: %+v", c)
+				}
+			},
+		},
+		{
+			name:  "extra label allowed",
+			patch: `{"metadata":{"labels":{"team":"a"}}}`,
+			check: func(t *testing.T, template *corev1.PodTemplateSpec) {
+				if template.Labels["team"] != "a" || template.Labels["version"] != "v1" {
+					t.Errorf("labels not merged: %v", template.Labels)
+				}
+			},
+		},
+		{name: "version label changed", patch: `{"metadata":{"labels":{"version":"v2"}}}`, wantErr: true},
+		{name: "app label removed", patch: `{"metadata":{"labels":{"app":null}}}`, wantErr: true},
+		{name: "unknown field", patch: `{"spec":{"nodeSelectr":{"disk":"ssd"}}}`, wantErr: true},
+		{name: "not a patch", patch: `[1]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &appsv1alpha1.Simpleapi{}
+			if tt.patch != "" {
+				app.Spec.PodTemplatePatch = &runtime.RawExtension{Raw: []byte(tt.patch)}
+			}
+			got := template()
+			err := applyPodTemplatePatch(app, got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyPodTemplatePatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if got.Labels["version"] != "v1" || got.Labels["app"] != "demo" {
+					t.Errorf("template changed on error: %v", got.Labels)
+				}
+				return
+			}
+			tt.check(t, got)
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+const (
+	grpcMatchService string = "service"
+
+	defaultGRPCVersionHeader string = "x-api-version"
+)
+
+// reconcileGRPCRoute applies the GRPCRoute with one rule per routed version, the route is
+// deleted while no version is routable as a GRPCRoute without rules matches every request
+func (r *SimpleapiReconciler) reconcileGRPCRoute(
+	ctx context.Context,
+	backends []routeBackend, namespace string,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) error {
+	if len(backends) == 0 {
+		return r.deleteOwned(ctx, SimpleAPIApp, &gatewayv1.GRPCRoute{}, getGRPCRouteName(SimpleAPIApp))
+	}
+	newGrpcroute := r.constructGRPCRoute(backends, namespace, SimpleAPIApp)
+
+	grpcroute := &gatewayv1.GRPCRoute{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: newGrpcroute.Name}, grpcroute)
+	if errors.IsNotFound(err) {
+		if err := controllerutil.SetControllerReference(SimpleAPIApp, newGrpcroute, r.Scheme); err != nil {
+			return err
+		}
+		return r.Create(ctx, newGrpcroute)
+	} else if err != nil {
+		return err
+	}
+	grpcroute.Spec = newGrpcroute.Spec
+	if err := controllerutil.SetControllerReference(SimpleAPIApp, grpcroute, r.Scheme); err != nil {
+		return err
+	}
+	return r.Update(ctx, grpcroute)
+}
+
+// constructGRPCRoute builds one rule per backend that matches the version by gRPC service
+// name or by the version header. Backend headers are set on the responses.
+func (r *SimpleapiReconciler) constructGRPCRoute(
+	backends []routeBackend,
+	namespace string,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) *gatewayv1.GRPCRoute {
+	rules := make([]gatewayv1.GRPCRouteRule, len(backends))
+	for i, backend := range backends {
+		rules[i] = gatewayv1.GRPCRouteRule{
+			Matches: []gatewayv1.GRPCRouteMatch{grpcRouteMatch(SimpleAPIApp, backend.Version)},
+			BackendRefs: []gatewayv1.GRPCBackendRef{
+				{
+					BackendRef: gatewayv1.BackendRef{
+						BackendObjectReference: gatewayv1.BackendObjectReference{
+							Name: gatewayv1.ObjectName(serviceName(backend.Version, SimpleAPIApp.Name)),
+							Port: ptr.To(gatewayv1.PortNumber(servicePortNumber(routedPort(SimpleAPIApp)))),
+						},
+						Weight: ptr.To[int32](1),
+					},
+				},
+			},
+		}
+		if len(backend.Headers) > 0 {
+			headers := make([]gatewayv1.HTTPHeader, len(backend.Headers))
+			for j, header := range backend.Headers {
+				headers[j] = gatewayv1.HTTPHeader{
+					Name:  gatewayv1.HTTPHeaderName(header.Name),
+					Value: header.Value,
+				}
+			}
+			rules[i].Filters = []gatewayv1.GRPCRouteFilter{
+				{
+					Type:                   gatewayv1.GRPCRouteFilterResponseHeaderModifier,
+					ResponseHeaderModifier: &gatewayv1.HTTPHeaderFilter{Set: headers},
+				},
+			}
+		}
+	}
+	grpcroute := &gatewayv1.GRPCRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getGRPCRouteName(SimpleAPIApp),
+			Namespace: namespace,
+		},
+		Spec: gatewayv1.GRPCRouteSpec{
+			CommonRouteSpec: gatewayv1.CommonRouteSpec{
+				ParentRefs: constructParentRefs(SimpleAPIApp),
+			},
+			Rules: rules,
+		},
+	}
+	if SimpleAPIApp.Spec.IngressHostName != "" {
+		grpcroute.Spec.Hostnames = []gatewayv1.Hostname{
+			gatewayv1.Hostname(SimpleAPIApp.Spec.IngressHostName),
+		}
+	}
+	return grpcroute
+}
+
+// grpcRouteMatch matches the version on the gRPC service name or, by default, on the
+// value of the version header
+func grpcRouteMatch(SimpleAPIApp *appsv1alpha1.Simpleapi, version string) gatewayv1.GRPCRouteMatch {
+	grpc := SimpleAPIApp.Spec.GRPC
+	if grpc == nil {
+		grpc = &appsv1alpha1.GRPCSpec{}
+	}
+	if grpc.Match == grpcMatchService {
+		return gatewayv1.GRPCRouteMatch{
+			Method: &gatewayv1.GRPCMethodMatch{
+				Type:    ptr.To(gatewayv1.GRPCMethodMatchExact),
+				Service: ptr.To(strings.ReplaceAll(grpc.Service, versionPlaceholder, version)),
+			},
+		}
+	}
+	header := grpc.Header
+	if header == "" {
+		header = defaultGRPCVersionHeader
+	}
+	return gatewayv1.GRPCRouteMatch{
+		Headers: []gatewayv1.GRPCHeaderMatch{
+			{
+				Type:  ptr.To(gatewayv1.GRPCHeaderMatchExact),
+				Name:  gatewayv1.GRPCHeaderName(header),
+				Value: version,
+			},
+		},
+	}
+}
+
+func getGRPCRouteName(SimpleAPIApp *appsv1alpha1.Simpleapi) string {
+	return fmt.Sprintf("%s-grpcroute", SimpleAPIApp.Name)
+}
@@ -13,32 +13,76 @@ import (
 	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 )
 
+// reconcileHTTPRoute applies the main HTTPRoute and, when a backend has its own hostname
+// such as the blueGreen preview, a second preview HTTPRoute. A route without backends is
+// deleted, Gateway API defaults an HTTPRoute without rules to match every path.
 func (r *SimpleapiReconciler) reconcileHTTPRoute(
 	ctx context.Context,
-	versions []string, namespace string,
+	backends []routeBackend, namespace string,
 	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) error {
+	mainBackends := []routeBackend{}
+	previewBackends := []routeBackend{}
+	for _, backend := range backends {
+		if backend.Host == "" {
+			mainBackends = append(mainBackends, backend)
+		} else {
+			previewBackends = append(previewBackends, backend)
+		}
+	}
+	mirrorVer := mirroredVersion(backendVersions(backends), SimpleAPIApp)
+
+	if len(mainBackends) == 0 {
+		if err := r.deleteOwned(ctx, SimpleAPIApp, &gatewayv1.HTTPRoute{}, getHTTPRouteName(SimpleAPIApp)); err != nil {
+			return err
+		}
+	} else if err := r.applyHTTPRoute(ctx, SimpleAPIApp, r.constructHTTPRoute(
+		getHTTPRouteName(SimpleAPIApp),
+		SimpleAPIApp.Spec.IngressHostName,
+		mainBackends,
+		mirrorVer,
+		namespace,
+		SimpleAPIApp,
+	)); err != nil {
+		return err
+	}
+
+	if len(previewBackends) == 0 {
+		return r.deleteOwned(ctx, SimpleAPIApp, &gatewayv1.HTTPRoute{}, getPreviewHTTPRouteName(SimpleAPIApp))
+	}
+	return r.applyHTTPRoute(ctx, SimpleAPIApp, r.constructHTTPRoute(
+		getPreviewHTTPRouteName(SimpleAPIApp),
+		previewBackends[0].Host,
+		previewBackends,
+		"",
+		namespace,
+		SimpleAPIApp,
+	))
+}
+
+func (r *SimpleapiReconciler) applyHTTPRoute(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+	newHttproute *gatewayv1.HTTPRoute,
 ) error {
 	httproute := &gatewayv1.HTTPRoute{}
 	// check if httproute already exists
 	err := r.Get(
 		ctx,
-		client.ObjectKey{Namespace: namespace, Name: getHTTPRouteName(SimpleAPIApp)},
+		client.ObjectKey{Namespace: newHttproute.Namespace, Name: newHttproute.Name},
 		httproute,
 	)
 
 	if errors.IsNotFound(err) {
-		// ingress does not exists and creating new one
-
-		httproute = r.constructHTTPRoute(versions, namespace, SimpleAPIApp)
-		if err := controllerutil.SetControllerReference(SimpleAPIApp, httproute, r.Scheme); err != nil {
+		// httproute does not exists and creating new one
+		if err := controllerutil.SetControllerReference(SimpleAPIApp, newHttproute, r.Scheme); err != nil {
 			return err
 		}
-		return r.Create(ctx, httproute)
+		return r.Create(ctx, newHttproute)
 
 	} else if err != nil {
 		return err
 	}
-	newHttproute := r.constructHTTPRoute(versions, namespace, SimpleAPIApp)
 	httproute.Spec = newHttproute.Spec
 	// this is kind of ensure ownership because the ingress not gets deleted but all the others does
 	if err := controllerutil.SetControllerReference(SimpleAPIApp, httproute, r.Scheme); err != nil {
@@ -48,19 +92,24 @@ func (r *SimpleapiReconciler) reconcileHTTPRoute(
 	return r.Update(ctx, httproute)
 }
 
+// constructHTTPRoute builds one rule per backend, the first rule is the stable one and
+// mirrors its traffic to mirrorVersion when it is set. Backend headers are set on the responses.
 func (r *SimpleapiReconciler) constructHTTPRoute(
-	versions []string,
+	name string,
+	hostname string,
+	backends []routeBackend,
+	mirrorVersion string,
 	namespace string,
 	SimpleAPIApp *appsv1alpha1.Simpleapi,
 ) *gatewayv1.HTTPRoute {
-	rules := make([]gatewayv1.HTTPRouteRule, len(versions))
-	for i, ver := range versions {
+	rules := make([]gatewayv1.HTTPRouteRule, len(backends))
+	for i, backend := range backends {
 		rules[i] = gatewayv1.HTTPRouteRule{
 			Matches: []gatewayv1.HTTPRouteMatch{
 				{
 					Path: &gatewayv1.HTTPPathMatch{
 						Type:  ptr.To(gatewayv1.PathMatchPathPrefix),
-						Value: ptr.To("/api/" + ver),
+						Value: ptr.To(backend.Path),
 					},
 				},
 			},
@@ -68,63 +117,109 @@ func (r *SimpleapiReconciler) constructHTTPRoute(
 				{
 					BackendRef: gatewayv1.BackendRef{
 						BackendObjectReference: gatewayv1.BackendObjectReference{
-							Name: gatewayv1.ObjectName(serviceName(ver, SimpleAPIApp.Name)),
-							Port: ptr.To(gatewayv1.PortNumber(SimpleAPIApp.Spec.Port)),
+							Name: gatewayv1.ObjectName(serviceName(backend.Version, SimpleAPIApp.Name)),
+							Port: ptr.To(gatewayv1.PortNumber(servicePortNumber(routedPort(SimpleAPIApp)))),
 						},
 						Weight: ptr.To[int32](1),
 					},
 				},
 			},
 		}
-	}
-	var httproute *gatewayv1.HTTPRoute
-	if SimpleAPIApp.Spec.IngressHostName == "" {
-		httproute = &gatewayv1.HTTPRoute{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      getHTTPRouteName(SimpleAPIApp),
-				Namespace: namespace,
-			},
-			Spec: gatewayv1.HTTPRouteSpec{
-				CommonRouteSpec: gatewayv1.CommonRouteSpec{
-					ParentRefs: []gatewayv1.ParentReference{
-						{
-							Name: gatewayv1.ObjectName(SimpleAPIApp.Spec.EnvoyGateway),
-							Namespace: ptr.To(
-								gatewayv1.Namespace(SimpleAPIApp.Spec.EnvoyGatewayNamespace),
-							),
-						},
-					},
+		if len(backend.Headers) > 0 {
+			headers := make([]gatewayv1.HTTPHeader, len(backend.Headers))
+			for j, header := range backend.Headers {
+				headers[j] = gatewayv1.HTTPHeader{
+					Name:  gatewayv1.HTTPHeaderName(header.Name),
+					Value: header.Value,
+				}
+			}
+			rules[i].Filters = []gatewayv1.HTTPRouteFilter{
+				{
+					Type:                   gatewayv1.HTTPRouteFilterResponseHeaderModifier,
+					ResponseHeaderModifier: &gatewayv1.HTTPHeaderFilter{Set: headers},
 				},
-				Rules: rules,
-			},
+			}
 		}
-	} else {
-		httproute = &gatewayv1.HTTPRoute{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      getHTTPRouteName(SimpleAPIApp),
-				Namespace: namespace,
-			},
-			Spec: gatewayv1.HTTPRouteSpec{
-				Hostnames: []gatewayv1.Hostname{
-					gatewayv1.Hostname(SimpleAPIApp.Spec.IngressHostName),
-				},
-				CommonRouteSpec: gatewayv1.CommonRouteSpec{
-					ParentRefs: []gatewayv1.ParentReference{
-						{
-							Name: gatewayv1.ObjectName(SimpleAPIApp.Spec.EnvoyGateway),
-							Namespace: ptr.To(
-								gatewayv1.Namespace(SimpleAPIApp.Spec.EnvoyGatewayNamespace),
-							),
-						},
-					},
+	}
+	// shadow the stable traffic to the newest version, the stable rule is always the first one
+	if mirrorVersion != "" && len(rules) > 0 {
+		rules[0].Filters = append(rules[0].Filters, gatewayv1.HTTPRouteFilter{
+			Type: gatewayv1.HTTPRouteFilterRequestMirror,
+			RequestMirror: &gatewayv1.HTTPRequestMirrorFilter{
+				BackendRef: gatewayv1.BackendObjectReference{
+					Name: gatewayv1.ObjectName(serviceName(mirrorVersion, SimpleAPIApp.Name)),
+					Port: ptr.To(gatewayv1.PortNumber(servicePortNumber(routedPort(SimpleAPIApp)))),
 				},
-				Rules: rules,
+				Percent: SimpleAPIApp.Spec.Mirror.Percent,
 			},
+		})
+	}
+	httproute := &gatewayv1.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: gatewayv1.HTTPRouteSpec{
+			CommonRouteSpec: gatewayv1.CommonRouteSpec{
+				ParentRefs: constructParentRefs(SimpleAPIApp),
+			},
+			Rules: rules,
+		},
+	}
+	if hostname != "" {
+		httproute.Spec.Hostnames = []gatewayv1.Hostname{
+			gatewayv1.Hostname(hostname),
 		}
 	}
 	return httproute
 }
 
+// constructParentRefs builds the HTTPRoute parentRefs from spec.parentRefs or,
+// when they are not set, from the envoyGateway fields
+func constructParentRefs(SimpleAPIApp *appsv1alpha1.Simpleapi) []gatewayv1.ParentReference {
+	refs := SimpleAPIApp.Spec.ParentRefs
+	if len(refs) == 0 {
+		refs = []appsv1alpha1.ParentRef{
+			{
+				Name:      SimpleAPIApp.Spec.EnvoyGateway,
+				Namespace: SimpleAPIApp.Spec.EnvoyGatewayNamespace,
+			},
+		}
+	}
+	parentRefs := make([]gatewayv1.ParentReference, len(refs))
+	for i, ref := range refs {
+		parentRefs[i] = gatewayv1.ParentReference{
+			Name: gatewayv1.ObjectName(ref.Name),
+		}
+		if ref.Namespace != "" {
+			parentRefs[i].Namespace = ptr.To(gatewayv1.Namespace(ref.Namespace))
+		}
+		if ref.SectionName != "" {
+			parentRefs[i].SectionName = ptr.To(gatewayv1.SectionName(ref.SectionName))
+		}
+		if ref.Port != nil {
+			parentRefs[i].Port = ptr.To(gatewayv1.PortNumber(*ref.Port))
+		}
+	}
+	return parentRefs
+}
+
+// mirroredVersion returns the newest version when mirroring is enabled and there is
+// an older stable version to mirror from, otherwise empty string
+func mirroredVersion(versions []string, SimpleAPIApp *appsv1alpha1.Simpleapi) string {
+	if SimpleAPIApp.Spec.Mirror == nil || !SimpleAPIApp.Spec.Mirror.Enabled {
+		return ""
+	}
+	if SimpleAPIApp.Spec.IngressType != "httproute" || len(versions) < 2 {
+		return ""
+	}
+	return versions[len(versions)-1]
+}
+
 func getHTTPRouteName(SimpleAPIApp *appsv1alpha1.Simpleapi) string {
 	return fmt.Sprintf("%s-httproute", SimpleAPIApp.Name)
 }
+
+func getPreviewHTTPRouteName(SimpleAPIApp *appsv1alpha1.Simpleapi) string {
+	return fmt.Sprintf("%s-preview-httproute", SimpleAPIApp.Name)
+}
@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestReconcileRoutesWithoutBackends(t *testing.T) {
+	app := testSimpleapi()
+	routes := map[string]struct {
+		name      string
+		object    func(meta metav1.ObjectMeta) client.Object
+		reconcile func(r *SimpleapiReconciler, backends []routeBackend) error
+		rules     func(obj client.Object) int
+	}{
+		"httproute": {
+			name: getHTTPRouteName(app),
+			object: func(meta metav1.ObjectMeta) client.Object {
+				return &gatewayv1.HTTPRoute{ObjectMeta: meta}
+			},
+			reconcile: func(r *SimpleapiReconciler, backends []routeBackend) error {
+				return r.reconcileHTTPRoute(context.Background(), backends, app.Namespace, app)
+			},
+			rules: func(obj client.Object) int { return len(obj.(*gatewayv1.HTTPRoute).Spec.Rules) },
+		},
+		"grpcroute": {
+			name: getGRPCRouteName(app),
+			object: func(meta metav1.ObjectMeta) client.Object {
+				return &gatewayv1.GRPCRoute{ObjectMeta: meta}
+			},
+			reconcile: func(r *SimpleapiReconciler, backends []routeBackend) error {
+				return r.reconcileGRPCRoute(context.Background(), backends, app.Namespace, app)
+			},
+			rules: func(obj client.Object) int { return len(obj.(*gatewayv1.GRPCRoute).Spec.Rules) },
+		},
+	}
+	tests := []struct {
+		name      string
+		existing  string // "", "owned" or "foreign"
+		backends  []routeBackend
+		wantRoute bool
+		wantRules int
+	}{
+		{name: "no backends creates no route", backends: nil, wantRoute: false},
+		{name: "no backends deletes the owned route", existing: "owned", backends: nil, wantRoute: false},
+		{name: "no backends keeps a foreign route", existing: "foreign", backends: nil, wantRoute: true},
+		{
+			name:      "backends create the route",
+			backends:  []routeBackend{{Version: "v1", Path: "/api/v1"}},
+			wantRoute: true,
+			wantRules: 1,
+		},
+	}
+	for kind, route := range routes {
+		for _, tt := range tests {
+			t.Run(kind+" "+tt.name, func(t *testing.T) {
+				objs := []client.Object{}
+				if tt.existing != "" {
+					meta := metav1.ObjectMeta{Name: route.name, Namespace: app.Namespace}
+					if tt.existing == "owned" {
+						meta.OwnerReferences = controlledBy(app)
+					}
+					objs = append(objs, route.object(meta))
+				}
+				r := newTestReconciler(t, objs...)
+				if err := route.reconcile(r, tt.backends); err != nil {
+					t.Fatalf("reconcile error = %v", err)
+				}
+
+				got := route.object(metav1.ObjectMeta{})
+				err := r.Get(context.Background(), client.ObjectKey{Namespace: app.Namespace, Name: route.name}, got)
+				if apierrors.IsNotFound(err) {
+					if tt.wantRoute {
+						t.Errorf("route %s not found", route.name)
+					}
+					return
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				if !tt.wantRoute {
+					t.Errorf("route %s exists with %d rules, want none", route.name, route.rules(got))
+				}
+				if tt.existing != "foreign" && route.rules(got) != tt.wantRules {
+					t.Errorf("route %s has %d rules, want %d", route.name, route.rules(got), tt.wantRules)
+				}
+			})
+		}
+	}
+}
+
+func TestReconcileHTTPRoutePreviewOnly(t *testing.T) {
+	app := testSimpleapi()
+	app.Spec.Strategy = strategyBlueGreen
+	app.Spec.BlueGreen = &appsv1alpha1.BlueGreenSpec{PreviewHostName: "preview.example.com"}
+	r := newTestReconciler(t, &gatewayv1.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            getHTTPRouteName(app),
+			Namespace:       app.Namespace,
+			OwnerReferences: controlledBy(app),
+		},
+	})
+
+	backends := []routeBackend{{Version: "v2", Path: "/api", Host: "preview.example.com"}}
+	if err := r.reconcileHTTPRoute(context.Background(), backends, app.Namespace, app); err != nil {
+		t.Fatalf("reconcileHTTPRoute() error = %v", err)
+	}
+	main := &gatewayv1.HTTPRoute{}
+	err := r.Get(context.Background(), client.ObjectKey{Namespace: app.Namespace, Name: getHTTPRouteName(app)}, main)
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("main HTTPRoute without backends still exists, error = %v", err)
+	}
+	preview := &gatewayv1.HTTPRoute{}
+	if err := r.Get(
+		context.Background(),
+		client.ObjectKey{Namespace: app.Namespace, Name: getPreviewHTTPRouteName(app)},
+		preview,
+	); err != nil {
+		t.Fatalf("preview HTTPRoute: %v", err)
+	}
+	if len(preview.Spec.Rules) != 1 {
+		t.Errorf("preview HTTPRoute has %d rules, want 1", len(preview.Spec.Rules))
+	}
+}
+
+func TestReconcileHTTPRouteMirror(t *testing.T) {
+	deprecation := []responseHeader{{Name: "Deprecation", Value: "@1735689600"}}
+	tests := []struct {
+		name        string
+		ingressType string
+		mirror      *appsv1alpha1.MirrorSpec
+		backends    []routeBackend
+		// wantFilters lists the filter types of every rule
+		wantFilters [][]gatewayv1.HTTPRouteFilterType
+		wantMirror  string
+	}{
+		{
+			name:        "mirror disabled",
+			ingressType: "httproute",
+			mirror:      &appsv1alpha1.MirrorSpec{Enabled: false},
+			backends:    []routeBackend{{Version: "v1", Path: "/api/v1"}, {Version: "v2", Path: "/api/v2"}},
+			wantFilters: [][]gatewayv1.HTTPRouteFilterType{nil, nil},
+		},
+		{
+			name:        "single version is not mirrored",
+			ingressType: "httproute",
+			mirror:      &appsv1alpha1.MirrorSpec{Enabled: true},
+			backends:    []routeBackend{{Version: "v1", Path: "/api/v1"}},
+			wantFilters: [][]gatewayv1.HTTPRouteFilterType{nil},
+		},
+		{
+			name:        "stable rule mirrors to the newest version",
+			ingressType: "httproute",
+			mirror:      &appsv1alpha1.MirrorSpec{Enabled: true, Percent: ptr.To[int32](10)},
+			backends: []routeBackend{
+				{Version: "v1", Path: "/api/v1"},
+				{Version: "v2", Path: "/api/v2"},
+				{Version: "v3", Path: "/api/v3"},
+			},
+			wantFilters: [][]gatewayv1.HTTPRouteFilterType{
+				{gatewayv1.HTTPRouteFilterRequestMirror},
+				nil,
+				nil,
+			},
+			wantMirror: "v3",
+		},
+		{
+			name:        "mirror follows the headers of a deprecated stable rule",
+			ingressType: "httproute",
+			mirror:      &appsv1alpha1.MirrorSpec{Enabled: true},
+			backends: []routeBackend{
+				{Version: "v1", Path: "/api/v1", Headers: deprecation},
+				{Version: "v2", Path: "/api/v2"},
+			},
+			wantFilters: [][]gatewayv1.HTTPRouteFilterType{
+				{gatewayv1.HTTPRouteFilterResponseHeaderModifier, gatewayv1.HTTPRouteFilterRequestMirror},
+				nil,
+			},
+			wantMirror: "v2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testSimpleapi()
+			app.Spec.IngressType = tt.ingressType
+			app.Spec.Mirror = tt.mirror
+			r := newTestReconciler(t)
+			if err := r.reconcileHTTPRoute(context.Background(), tt.backends, app.Namespace, app); err != nil {
+				t.Fatalf("reconcileHTTPRoute() error = %v", err)
+			}
+			route := &gatewayv1.HTTPRoute{}
+			if err := r.Get(
+				context.Background(),
+				client.ObjectKey{Namespace: app.Namespace, Name: getHTTPRouteName(app)},
+				route,
+			); err != nil {
+				t.Fatal(err)
+			}
+			if len(route.Spec.Rules) != len(tt.wantFilters) {
+				t.Fatalf("HTTPRoute has %d rules, want %d", len(route.Spec.Rules), len(tt.wantFilters))
+			}
+			for i, rule := range route.Spec.Rules {
+				got := []gatewayv1.HTTPRouteFilterType{}
+				for _, filter := range rule.Filters {
+					got = append(got, filter.Type)
+					if filter.RequestMirror == nil {
+						continue
+					}
+					if name := string(filter.RequestMirror.BackendRef.Name); name != serviceName(tt.wantMirror, app.Name) {
+						t.Errorf("rule %d mirrors to %s, want %s", i, name, serviceName(tt.wantMirror, app.Name))
+					}
+					if !reflect.DeepEqual(filter.RequestMirror.Percent, tt.mirror.Percent) {
+						t.Errorf("rule %d mirror percent = %v, want %v", i, filter.RequestMirror.Percent, tt.mirror.Percent)
+					}
+				}
+				if !slices.Equal(got, tt.wantFilters[i]) {
+					t.Errorf("rule %d filters = %v, want %v", i, got, tt.wantFilters[i])
+				}
+			}
+		})
+	}
+}
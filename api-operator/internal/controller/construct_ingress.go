@@ -3,13 +3,19 @@ package controller
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 
 	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	networkingv1 "k8s.io/api/networking/v1"
 	// for gateway api networkingv1 "sigs.k8s.io/gateway-api/apis/v1"
@@ -19,33 +25,198 @@ const (
 	ingressClassName string = "nginx"
 )
 
+// reconcileIngress applies the main Ingress and one Ingress per deprecated version, whose
+// custom headers ConfigMap adds the deprecation headers to the path of that version only.
+// A deprecated version whose Ingress is refused, for example by the ingress-nginx admission
+// webhook, is routed through the main Ingress without the headers and reported on the
+// returned condition, which is nil while no version is deprecated.
 func (r *SimpleapiReconciler) reconcileIngress(
 	ctx context.Context,
-	versions []string,
+	backends []routeBackend,
+	namespace string,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) (*metav1.Condition, error) {
+	logger := log.FromContext(ctx)
+	mainBackends := []routeBackend{}
+	deprecated := map[string]bool{}
+	failures := []string{}
+	for _, backend := range backends {
+		if len(backend.Headers) == 0 {
+			mainBackends = append(mainBackends, backend)
+			continue
+		}
+		if err := r.applyDeprecatedIngress(ctx, backend, namespace, SimpleAPIApp); err != nil {
+			logger.Error(err, "Failed to apply the Ingress of deprecated version", "version", backend.Version)
+			failures = append(failures, fmt.Sprintf("%s: %v", backend.Version, err))
+			mainBackends = append(mainBackends, routeBackend{Version: backend.Version, Path: backend.Path, Host: backend.Host})
+			continue
+		}
+		deprecated[getDeprecatedIngressName(SimpleAPIApp, backend.Version)] = true
+	}
+
+	// nothing is routed before the first version is available, or once every version is
+	// deprecated and served by its own Ingress
+	mainIngress := r.constructIngress(mainBackends, namespace, SimpleAPIApp)
+	if len(mainIngress.Spec.Rules) == 0 {
+		if err := r.deleteOwned(ctx, SimpleAPIApp, &networkingv1.Ingress{}, mainIngress.Name); err != nil {
+			return nil, err
+		}
+	} else if err := r.applyIngress(ctx, SimpleAPIApp, mainIngress); err != nil {
+		return nil, err
+	}
+
+	// remove the Ingresses of versions that are no longer deprecated or routed, and the headers
+	// of versions that are no longer deprecated, a refused Ingress keeps its headers for the retry
+	headers := map[string]bool{}
+	for _, backend := range backends {
+		if len(backend.Headers) > 0 {
+			headers[getDeprecatedIngressName(SimpleAPIApp, backend.Version)] = true
+		}
+	}
+	for _, stale := range []struct {
+		list client.ObjectList
+		keep map[string]bool
+	}{
+		{&networkingv1.IngressList{}, deprecated},
+		{&corev1.ConfigMapList{}, headers},
+	} {
+		if err := r.List(
+			ctx,
+			stale.list,
+			client.InNamespace(namespace),
+			client.MatchingLabels{simpleapiLabel: SimpleAPIApp.Name},
+			client.HasLabels{deprecatedVersionLabel},
+		); err != nil {
+			return nil, err
+		}
+		if err := meta.EachListItem(stale.list, func(item runtime.Object) error {
+			obj := item.(client.Object)
+			if stale.keep[obj.GetName()] || !metav1.IsControlledBy(obj, SimpleAPIApp) {
+				return nil
+			}
+			return client.IgnoreNotFound(r.Delete(ctx, obj))
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return deprecationCondition(SimpleAPIApp, backends, failures), nil
+}
+
+// applyDeprecatedIngress applies the custom headers ConfigMap and the Ingress of a deprecated version
+func (r *SimpleapiReconciler) applyDeprecatedIngress(
+	ctx context.Context,
+	backend routeBackend,
 	namespace string,
 	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) error {
+	name := getDeprecatedIngressName(SimpleAPIApp, backend.Version)
+	labels := map[string]string{
+		simpleapiLabel:         SimpleAPIApp.Name,
+		deprecatedVersionLabel: backend.Version,
+	}
+
+	headers := &corev1.ConfigMap{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, headers)
+	if errors.IsNotFound(err) {
+		headers = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+			Data:       deprecationHeaders(backend.Headers),
+		}
+		if err := controllerutil.SetControllerReference(SimpleAPIApp, headers, r.Scheme); err != nil {
+			return err
+		}
+		err = r.Create(ctx, headers)
+	} else if err != nil {
+		return err
+	} else if !metav1.IsControlledBy(headers, SimpleAPIApp) {
+		return fmt.Errorf("ConfigMap %s already exists and was not created by the operator", name)
+	} else {
+		headers.Data = deprecationHeaders(backend.Headers)
+		err = r.Update(ctx, headers)
+	}
+	if err != nil {
+		return err
+	}
+
+	ingress := r.constructIngress([]routeBackend{backend}, namespace, SimpleAPIApp)
+	ingress.Name = name
+	ingress.Labels = labels
+	ingress.Annotations[customHeadersAnnotation] = namespace + "/" + name
+	return r.applyIngress(ctx, SimpleAPIApp, ingress)
+}
+
+// deprecationCondition reports the deprecated versions whose Ingress could not be applied
+func deprecationCondition(
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+	backends []routeBackend,
+	failures []string,
+) *metav1.Condition {
+	if !slices.ContainsFunc(backends, func(b routeBackend) bool { return len(b.Headers) > 0 }) {
+		return nil
+	}
+	if len(failures) > 0 {
+		return &metav1.Condition{
+			Type:   appsv1alpha1.ConditionDeprecationApplied,
+			Status: metav1.ConditionFalse,
+			Reason: "IngressFailed",
+			Message: fmt.Sprintf(
+				"deprecated versions are routed without the deprecation headers: %s",
+				strings.Join(failures, "; "),
+			),
+			ObservedGeneration: SimpleAPIApp.Generation,
+		}
+	}
+	return &metav1.Condition{
+		Type:               appsv1alpha1.ConditionDeprecationApplied,
+		Status:             metav1.ConditionTrue,
+		Reason:             "HeadersApplied",
+		Message:            "deprecated versions are routed with the deprecation headers",
+		ObservedGeneration: SimpleAPIApp.Generation,
+	}
+}
+
+func (r *SimpleapiReconciler) applyIngress(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+	newIngress *networkingv1.Ingress,
 ) error {
 	ingress := &networkingv1.Ingress{}
 
 	// Check if the Ingress already exists
 	err := r.Get(
 		ctx,
-		client.ObjectKey{Namespace: namespace, Name: getIngressName(SimpleAPIApp)},
+		client.ObjectKey{Namespace: newIngress.Namespace, Name: newIngress.Name},
 		ingress,
 	)
 	// Check if the Ingress already existso
 	if errors.IsNotFound(err) {
 		// ingress does not exists and creating new one
-		ingress = r.constructIngress(versions, namespace, SimpleAPIApp)
-		if err := controllerutil.SetControllerReference(SimpleAPIApp, ingress, r.Scheme); err != nil {
+		if err := controllerutil.SetControllerReference(SimpleAPIApp, newIngress, r.Scheme); err != nil {
 			return err
 		}
-		return r.Create(ctx, ingress)
+		return r.Create(ctx, newIngress)
 	} else if err != nil {
 		return err
 	}
-	newIngress := r.constructIngress(versions, namespace, SimpleAPIApp)
+	// tls is not generated, a tls section added to the Ingress by others is kept
+	tls := ingress.Spec.TLS
 	ingress.Spec = newIngress.Spec
+	if ingress.Spec.TLS == nil {
+		ingress.Spec.TLS = tls
+	}
+	// labels and annotations set by others are kept
+	for k, v := range newIngress.Labels {
+		if ingress.Labels == nil {
+			ingress.Labels = map[string]string{}
+		}
+		ingress.Labels[k] = v
+	}
+	for k, v := range newIngress.Annotations {
+		if ingress.Annotations == nil {
+			ingress.Annotations = map[string]string{}
+		}
+		ingress.Annotations[k] = v
+	}
 	// this is kind of ensure ownership because the ingress not gets deleted but all the others does
 	if err := controllerutil.SetControllerReference(SimpleAPIApp, ingress, r.Scheme); err != nil {
 		return err
@@ -53,90 +224,77 @@ func (r *SimpleapiReconciler) reconcileIngress(
 	return r.Update(ctx, ingress)
 }
 
+// constructIngress groups the backend paths into one rule per host
 func (r *SimpleapiReconciler) constructIngress(
-	versions []string,
+	backends []routeBackend,
 	namespace string,
 	SimpleAPIApp *appsv1alpha1.Simpleapi,
 ) *networkingv1.Ingress {
-	paths := make([]networkingv1.HTTPIngressPath, len(versions))
+	hosts := []string{SimpleAPIApp.Spec.IngressHostName}
+	paths := map[string][]networkingv1.HTTPIngressPath{}
 
-	for i, ver := range versions {
+	for _, backend := range backends {
+		host := backend.Host
+		if host == "" {
+			host = SimpleAPIApp.Spec.IngressHostName
+		}
+		if _, ok := paths[host]; !ok && host != SimpleAPIApp.Spec.IngressHostName {
+			hosts = append(hosts, host)
+		}
 
 		path := networkingv1.HTTPIngressPath{
-			Path: "/api/" + ver,
+			Path: backend.Path,
 			PathType: func() *networkingv1.PathType {
 				pt := networkingv1.PathTypePrefix
 				return &pt
 			}(),
 			Backend: networkingv1.IngressBackend{
 				Service: &networkingv1.IngressServiceBackend{
-					Name: serviceName(ver, SimpleAPIApp.Name),
+					Name: serviceName(backend.Version, SimpleAPIApp.Name),
+					// by number, Services of older versions may predate the port names
 					Port: networkingv1.ServiceBackendPort{
-						Number: SimpleAPIApp.Spec.Port,
+						Number: servicePortNumber(routedPort(SimpleAPIApp)),
 					},
 				},
 			},
 		}
-		paths[i] = path
+		paths[host] = append(paths[host], path)
 	}
-	var ingress *networkingv1.Ingress
-	if SimpleAPIApp.Spec.IngressHostName == "" {
-		ingress = &networkingv1.Ingress{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:        getIngressName(SimpleAPIApp),
-				Namespace:   namespace,
-				Annotations: map[string]string{
-					//	"nginx.ingress.kubernetes.io/rewrite-target": "/",
-				},
-			},
-			Spec: networkingv1.IngressSpec{
-				IngressClassName: ptr.To(ingressClassName),
-				Rules: []networkingv1.IngressRule{
-					{
-						IngressRuleValue: networkingv1.IngressRuleValue{
-							HTTP: &networkingv1.HTTPIngressRuleValue{
-								Paths: paths,
-							},
-						},
-					},
-				},
-				// TLS: []networkingv1.IngressTLS{
-				// 	{
-				// 		Hosts:      []string{"my-api.bankingcircle.net"},
-				// 		SecretName: "my-api-tls-secret",
-				// 	},
-				// },
-			},
+
+	// a rule without paths is rejected by the API server, hosts with nothing routed are left out
+	rules := []networkingv1.IngressRule{}
+	for _, host := range hosts {
+		if len(paths[host]) == 0 {
+			continue
 		}
-	} else {
-		ingress = &networkingv1.Ingress{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:        getIngressName(SimpleAPIApp),
-				Namespace:   namespace,
-				Annotations: map[string]string{
-					//	"nginx.ingress.kubernetes.io/rewrite-target": "/",
+		rules = append(rules, networkingv1.IngressRule{
+			Host: host,
+			IngressRuleValue: networkingv1.IngressRuleValue{
+				HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: paths[host],
 				},
 			},
-			Spec: networkingv1.IngressSpec{
-				IngressClassName: ptr.To(ingressClassName),
-				Rules: []networkingv1.IngressRule{
-					{
-						Host: SimpleAPIApp.Spec.IngressHostName,
-						IngressRuleValue: networkingv1.IngressRuleValue{
-							HTTP: &networkingv1.HTTPIngressRuleValue{
-								Paths: paths,
-							},
-						},
-					},
-				},
-				// TLS: []networkingv1.IngressTLS{
-				// 	{
-				// 		Hosts:      []string{"my-api.bankingcircle.net"},
-				// 		SecretName: "my-api-tls-secret",
-				// 	},
-				// },
+		})
+	}
+
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        getIngressName(SimpleAPIApp),
+			Namespace:   namespace,
+			Annotations: map[string]string{
+				//	"nginx.ingress.kubernetes.io/rewrite-target": "/",
 			},
-		}
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: ptr.To(ingressClassName),
+			Rules:            rules,
+			// TLS: []networkingv1.IngressTLS{
+			// 	{
+			// 		Hosts:      []string{"my-api.bankingcircle.net"},
+			// 		SecretName: "my-api-tls-secret",
+			// 	},
+			// },
+		},
 	}
 	return ingress
 }
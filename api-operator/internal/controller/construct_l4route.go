@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+)
+
+// currentBackend returns the version a TCPRoute or TLSRoute sends traffic to, raw TCP and TLS
+// passthrough have no path to split versions on so only the blueGreen active version, which
+// routeBackends puts first, or else the newest routed version is exposed
+func currentBackend(SimpleAPIApp *appsv1alpha1.Simpleapi, backends []routeBackend) (routeBackend, bool) {
+	if len(backends) == 0 {
+		return routeBackend{}, false
+	}
+	if SimpleAPIApp.Spec.Strategy == strategyBlueGreen {
+		return backends[0], true
+	}
+	return backends[len(backends)-1], true
+}
+
+// reconcileL4Route applies the TCPRoute or TLSRoute of the current version, the route is
+// left as it is while no version is routable yet
+func (r *SimpleapiReconciler) reconcileL4Route(
+	ctx context.Context,
+	backends []routeBackend, namespace string,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) error {
+	backend, ok := currentBackend(SimpleAPIApp, backends)
+	if !ok {
+		return nil
+	}
+	backendRefs := []gatewayv1.BackendRef{
+		{
+			BackendObjectReference: gatewayv1.BackendObjectReference{
+				Name: gatewayv1.ObjectName(serviceName(backend.Version, SimpleAPIApp.Name)),
+				Port: ptr.To(gatewayv1.PortNumber(servicePortNumber(routedPort(SimpleAPIApp)))),
+			},
+			Weight: ptr.To[int32](1),
+		},
+	}
+
+	var route, newRoute client.Object
+	if SimpleAPIApp.Spec.IngressType == "tlsroute" {
+		route = &gatewayv1alpha2.TLSRoute{}
+		newRoute = constructTLSRoute(backendRefs, namespace, SimpleAPIApp)
+	} else {
+		route = &gatewayv1alpha2.TCPRoute{}
+		newRoute = constructTCPRoute(backendRefs, namespace, SimpleAPIApp)
+	}
+
+	err := r.Get(ctx, client.ObjectKeyFromObject(newRoute), route)
+	if errors.IsNotFound(err) {
+		if err := controllerutil.SetControllerReference(SimpleAPIApp, newRoute, r.Scheme); err != nil {
+			return err
+		}
+		return r.Create(ctx, newRoute)
+	} else if err != nil {
+		return err
+	}
+	switch route := route.(type) {
+	case *gatewayv1alpha2.TLSRoute:
+		route.Spec = newRoute.(*gatewayv1alpha2.TLSRoute).Spec
+	case *gatewayv1alpha2.TCPRoute:
+		route.Spec = newRoute.(*gatewayv1alpha2.TCPRoute).Spec
+	}
+	if err := controllerutil.SetControllerReference(SimpleAPIApp, route, r.Scheme); err != nil {
+		return err
+	}
+	return r.Update(ctx, route)
+}
+
+func constructTCPRoute(
+	backendRefs []gatewayv1.BackendRef,
+	namespace string,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) *gatewayv1alpha2.TCPRoute {
+	return &gatewayv1alpha2.TCPRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getTCPRouteName(SimpleAPIApp),
+			Namespace: namespace,
+		},
+		Spec: gatewayv1alpha2.TCPRouteSpec{
+			CommonRouteSpec: gatewayv1.CommonRouteSpec{
+				ParentRefs: constructParentRefs(SimpleAPIApp),
+			},
+			Rules: []gatewayv1alpha2.TCPRouteRule{{BackendRefs: backendRefs}},
+		},
+	}
+}
+
+// constructTLSRoute builds a TLSRoute for a passthrough listener, the ingressHostName is
+// matched against the SNI of the connection
+func constructTLSRoute(
+	backendRefs []gatewayv1.BackendRef,
+	namespace string,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) *gatewayv1alpha2.TLSRoute {
+	tlsroute := &gatewayv1alpha2.TLSRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getTLSRouteName(SimpleAPIApp),
+			Namespace: namespace,
+		},
+		Spec: gatewayv1alpha2.TLSRouteSpec{
+			CommonRouteSpec: gatewayv1.CommonRouteSpec{
+				ParentRefs: constructParentRefs(SimpleAPIApp),
+			},
+			Rules: []gatewayv1alpha2.TLSRouteRule{{BackendRefs: backendRefs}},
+		},
+	}
+	if SimpleAPIApp.Spec.IngressHostName != "" {
+		tlsroute.Spec.Hostnames = []gatewayv1.Hostname{
+			gatewayv1.Hostname(SimpleAPIApp.Spec.IngressHostName),
+		}
+	}
+	return tlsroute
+}
+
+func getTCPRouteName(SimpleAPIApp *appsv1alpha1.Simpleapi) string {
+	return fmt.Sprintf("%s-tcproute", SimpleAPIApp.Name)
+}
+
+func getTLSRouteName(SimpleAPIApp *appsv1alpha1.Simpleapi) string {
+	return fmt.Sprintf("%s-tlsroute", SimpleAPIApp.Name)
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"testing"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+)
+
+func TestCurrentBackend(t *testing.T) {
+	tests := []struct {
+		name        string
+		strategy    string
+		backends    []routeBackend
+		wantVersion string
+		wantOK      bool
+	}{
+		{"no backends", strategyPathPerVersion, nil, "", false},
+		{
+			"pathPerVersion newest version",
+			strategyPathPerVersion,
+			[]routeBackend{{Version: "v1", Path: "/api/v1"}, {Version: "v2", Path: "/api/v2"}},
+			"v2",
+			true,
+		},
+		{
+			"blueGreen active over preview path",
+			strategyBlueGreen,
+			[]routeBackend{{Version: "v1", Path: "/api"}, {Version: "v2", Path: "/preview/api"}},
+			"v1",
+			true,
+		},
+		{
+			"blueGreen active over preview host",
+			strategyBlueGreen,
+			[]routeBackend{{Version: "v1", Path: "/api"}, {Version: "v2", Path: "/api", Host: "preview.example.com"}},
+			"v1",
+			true,
+		},
+		{"blueGreen active only", strategyBlueGreen, []routeBackend{{Version: "v1", Path: "/api"}}, "v1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &appsv1alpha1.Simpleapi{Spec: appsv1alpha1.SimpleapiSpec{Strategy: tt.strategy}}
+			got, ok := currentBackend(app, tt.backends)
+			if ok != tt.wantOK || got.Version != tt.wantVersion {
+				t.Errorf("currentBackend() = %q, %v, want %q, %v", got.Version, ok, tt.wantVersion, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// ingressControllerNamespace is where the nginx ingress controller runs by default
+	ingressControllerNamespace string = "ingress-nginx"
+	// defaultMonitoringNamespace is where Prometheus runs by default
+	defaultMonitoringNamespace string = "monitoring"
+	namespaceNameLabel         string = "kubernetes.io/metadata.name"
+)
+
+func (r *SimpleapiReconciler) reconcileNetworkPolicy(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) error {
+	if SimpleAPIApp.Spec.NetworkPolicy == nil || !SimpleAPIApp.Spec.NetworkPolicy.Enabled {
+		return r.deleteOwned(
+			ctx,
+			SimpleAPIApp,
+			&networkingv1.NetworkPolicy{},
+			getNetworkPolicyName(SimpleAPIApp),
+		)
+	}
+
+	networkPolicy := &networkingv1.NetworkPolicy{}
+	err := r.Get(
+		ctx,
+		client.ObjectKey{Namespace: SimpleAPIApp.Namespace, Name: getNetworkPolicyName(SimpleAPIApp)},
+		networkPolicy,
+	)
+	if errors.IsNotFound(err) {
+		networkPolicy = r.constructNetworkPolicy(SimpleAPIApp)
+		if err := controllerutil.SetControllerReference(SimpleAPIApp, networkPolicy, r.Scheme); err != nil {
+			return err
+		}
+		return r.Create(ctx, networkPolicy)
+	} else if err != nil {
+		return err
+	}
+	networkPolicy.Spec = r.constructNetworkPolicy(SimpleAPIApp).Spec
+	if err := controllerutil.SetControllerReference(SimpleAPIApp, networkPolicy, r.Scheme); err != nil {
+		return err
+	}
+	return r.Update(ctx, networkPolicy)
+}
+
+// constructNetworkPolicy selects the pods of every version through the app and simpleapi labels,
+// the same labels the version Services select, so other Simpleapis sharing the app label are not affected
+func (r *SimpleapiReconciler) constructNetworkPolicy(
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) *networkingv1.NetworkPolicy {
+	spec := SimpleAPIApp.Spec.NetworkPolicy
+	policyTypes := []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+
+	var egress []networkingv1.NetworkPolicyEgressRule
+	if len(spec.Egress) > 0 {
+		policyTypes = append(policyTypes, networkingv1.PolicyTypeEgress)
+		// without DNS none of the allowlisted hostnames can be resolved
+		egress = append(egress, networkingv1.NetworkPolicyEgressRule{
+			To: []networkingv1.NetworkPolicyPeer{
+				{
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{namespaceNameLabel: "kube-system"},
+					},
+				},
+			},
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: ptr.To(corev1.ProtocolUDP), Port: ptr.To(intstr.FromInt32(53))},
+				{Protocol: ptr.To(corev1.ProtocolTCP), Port: ptr.To(intstr.FromInt32(53))},
+			},
+		})
+		egress = append(egress, spec.Egress...)
+	}
+
+	ingress := []networkingv1.NetworkPolicyIngressRule{
+		{
+			From: []networkingv1.NetworkPolicyPeer{
+				{
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchExpressions: []metav1.LabelSelectorRequirement{
+							{
+								Key:      namespaceNameLabel,
+								Operator: metav1.LabelSelectorOpIn,
+								Values:   networkPolicyIngressNamespaces(SimpleAPIApp),
+							},
+						},
+					},
+				},
+			},
+			Ports: []networkingv1.NetworkPolicyPort{
+				{
+					Protocol: ptr.To(portProtocol(routedPort(SimpleAPIApp))),
+					Port:     ptr.To(intstr.FromInt32(routedPort(SimpleAPIApp).ContainerPort)),
+				},
+			},
+		},
+	}
+	// hook Jobs such as postRollout smoke tests call the version Service from the same namespace
+	if SimpleAPIApp.Spec.Hooks != nil {
+		ingress = append(ingress, networkingv1.NetworkPolicyIngressRule{
+			From: []networkingv1.NetworkPolicyPeer{
+				{
+					PodSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{simpleapiLabel: SimpleAPIApp.Name},
+						MatchExpressions: []metav1.LabelSelectorRequirement{
+							{Key: hookLabel, Operator: metav1.LabelSelectorOpExists},
+						},
+					},
+				},
+			},
+		})
+	}
+	// Prometheus scrapes the pods behind the ServiceMonitor from its own namespace
+	if monitoring := SimpleAPIApp.Spec.Monitoring; monitoring != nil && monitoring.Enabled {
+		namespace := monitoring.Namespace
+		if namespace == "" {
+			namespace = defaultMonitoringNamespace
+		}
+		port := monitoredPort(SimpleAPIApp)
+		ingress = append(ingress, networkingv1.NetworkPolicyIngressRule{
+			From: []networkingv1.NetworkPolicyPeer{
+				{
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{namespaceNameLabel: namespace},
+					},
+				},
+			},
+			Ports: []networkingv1.NetworkPolicyPort{
+				{
+					Protocol: ptr.To(portProtocol(port)),
+					Port:     ptr.To(intstr.FromInt32(port.ContainerPort)),
+				},
+			},
+		})
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getNetworkPolicyName(SimpleAPIApp),
+			Namespace: SimpleAPIApp.Namespace,
+			Labels: map[string]string{
+				"app":          SimpleAPIApp.Labels["app"],
+				simpleapiLabel: SimpleAPIApp.Name,
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app":          SimpleAPIApp.Labels["app"],
+					simpleapiLabel: SimpleAPIApp.Name,
+				},
+			},
+			Ingress:     ingress,
+			Egress:      egress,
+			PolicyTypes: policyTypes,
+		},
+	}
+}
+
+// networkPolicyIngressNamespaces returns the configured namespaces or the ones the
+// gateway or ingress controller traffic comes from
+func networkPolicyIngressNamespaces(SimpleAPIApp *appsv1alpha1.Simpleapi) []string {
+	if len(SimpleAPIApp.Spec.NetworkPolicy.IngressNamespaces) > 0 {
+		return SimpleAPIApp.Spec.NetworkPolicy.IngressNamespaces
+	}
+	if SimpleAPIApp.Spec.IngressType == "ingress" {
+		return []string{ingressControllerNamespace}
+	}
+	namespaces := []string{}
+	seen := map[string]bool{}
+	for _, ref := range constructParentRefs(SimpleAPIApp) {
+		ns := SimpleAPIApp.Namespace
+		if ref.Namespace != nil {
+			ns = string(*ref.Namespace)
+		}
+		if !seen[ns] {
+			seen[ns] = true
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+func getNetworkPolicyName(SimpleAPIApp *appsv1alpha1.Simpleapi) string {
+	return fmt.Sprintf("%s-netpol", SimpleAPIApp.Name)
+}
@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestConstructNetworkPolicy(t *testing.T) {
+	egress := []networkingv1.NetworkPolicyEgressRule{{
+		To: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8"}}},
+	}}
+	ports := []appsv1alpha1.PortSpec{
+		{Name: "grpc", ContainerPort: 9000, ServicePort: ptr.To[int32](90), AppProtocol: "grpc"},
+		{Name: "metrics", ContainerPort: 9100, Protocol: corev1.ProtocolTCP},
+	}
+	// rule describes an ingress rule by its namespaces, or hook pods, and its port
+	type rule struct {
+		namespaces []string
+		hooks      bool
+		port       int32
+	}
+	tests := []struct {
+		name        string
+		mutate      func(app *appsv1alpha1.Simpleapi)
+		wantRules   []rule
+		wantEgress  int
+		wantTypes   []networkingv1.PolicyType
+		wantDNSRule bool
+	}{
+		{
+			name: "gateway namespaces of the parentRefs",
+			mutate: func(app *appsv1alpha1.Simpleapi) {
+				app.Spec.IngressType = "httproute"
+				app.Spec.ParentRefs = []appsv1alpha1.ParentRef{
+					{Name: "a", Namespace: "gateways"},
+					{Name: "b", Namespace: "gateways"},
+					{Name: "c"},
+				}
+			},
+			wantRules: []rule{{namespaces: []string{"gateways", "default"}, port: 8080}},
+			wantTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+		{
+			name:      "ingress controller namespace",
+			mutate:    func(app *appsv1alpha1.Simpleapi) { app.Spec.IngressType = "ingress" },
+			wantRules: []rule{{namespaces: []string{ingressControllerNamespace}, port: 8080}},
+			wantTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+		{
+			name: "configured namespaces on the routed container port",
+			mutate: func(app *appsv1alpha1.Simpleapi) {
+				app.Spec.IngressType = "grpcroute"
+				app.Spec.Ports = ports
+				app.Spec.NetworkPolicy.IngressNamespaces = []string{"edge"}
+			},
+			wantRules: []rule{{namespaces: []string{"edge"}, port: 9000}},
+			wantTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+		{
+			name: "egress allowlist with DNS",
+			mutate: func(app *appsv1alpha1.Simpleapi) {
+				app.Spec.IngressType = "ingress"
+				app.Spec.NetworkPolicy.Egress = egress
+			},
+			wantRules:   []rule{{namespaces: []string{ingressControllerNamespace}, port: 8080}},
+			wantEgress:  2,
+			wantDNSRule: true,
+			wantTypes:   []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+		},
+		{
+			name: "hook Jobs and Prometheus admitted",
+			mutate: func(app *appsv1alpha1.Simpleapi) {
+				app.Spec.IngressType = "ingress"
+				app.Spec.Ports = append([]appsv1alpha1.PortSpec{{Name: "http", ContainerPort: 8080}}, ports...)
+				app.Spec.Hooks = &appsv1alpha1.HooksSpec{}
+				app.Spec.Monitoring = &appsv1alpha1.MonitoringSpec{Enabled: true, Port: "metrics"}
+			},
+			wantRules: []rule{
+				{namespaces: []string{ingressControllerNamespace}, port: 8080},
+				{hooks: true},
+				{namespaces: []string{defaultMonitoringNamespace}, port: 9100},
+			},
+			wantTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testSimpleapi()
+			app.Spec.Port = 8080
+			app.Spec.NetworkPolicy = &appsv1alpha1.NetworkPolicySpec{Enabled: true}
+			tt.mutate(app)
+			r := newTestReconciler(t)
+			got := r.constructNetworkPolicy(app).Spec
+
+			wantSelector := map[string]string{"app": "demo", simpleapiLabel: "demo"}
+			if !maps.Equal(got.PodSelector.MatchLabels, wantSelector) {
+				t.Errorf("podSelector = %v, want %v", got.PodSelector.MatchLabels, wantSelector)
+			}
+			if !slices.Equal(got.PolicyTypes, tt.wantTypes) {
+				t.Errorf("policyTypes = %v, want %v", got.PolicyTypes, tt.wantTypes)
+			}
+			if len(got.Egress) != tt.wantEgress {
+				t.Errorf("egress has %d rules, want %d", len(got.Egress), tt.wantEgress)
+			}
+			if tt.wantDNSRule && got.Egress[0].To[0].NamespaceSelector.MatchLabels[namespaceNameLabel] != "kube-system" {
+				t.Errorf("first egress rule = %+v, want DNS to kube-system", got.Egress[0])
+			}
+
+			if len(got.Ingress) != len(tt.wantRules) {
+				t.Fatalf("ingress has %d rules, want %d", len(got.Ingress), len(tt.wantRules))
+			}
+			for i, want := range tt.wantRules {
+				ingress := got.Ingress[i]
+				from := ingress.From[0]
+				if want.hooks {
+					if from.PodSelector == nil || from.PodSelector.MatchLabels[simpleapiLabel] != "demo" ||
+						from.PodSelector.MatchExpressions[0].Key != hookLabel {
+						t.Errorf("rule %d from = %+v, want the hook pods", i, from)
+					}
+					continue
+				}
+				namespaces := from.NamespaceSelector.MatchLabels[namespaceNameLabel]
+				if expressions := from.NamespaceSelector.MatchExpressions; len(expressions) > 0 {
+					if !slices.Equal(expressions[0].Values, want.namespaces) {
+						t.Errorf("rule %d namespaces = %v, want %v", i, expressions[0].Values, want.namespaces)
+					}
+				} else if !slices.Equal([]string{namespaces}, want.namespaces) {
+					t.Errorf("rule %d namespace = %s, want %v", i, namespaces, want.namespaces)
+				}
+				port := ingress.Ports[0]
+				if *port.Port != intstr.FromInt32(want.port) || *port.Protocol != corev1.ProtocolTCP {
+					t.Errorf("rule %d port = %v/%s, want %d/TCP", i, port.Port, *port.Protocol, want.port)
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileNetworkPolicyDisabled(t *testing.T) {
+	app := testSimpleapi()
+	for _, owned := range []bool{true, false} {
+		policy := &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: getNetworkPolicyName(app), Namespace: app.Namespace},
+		}
+		if owned {
+			policy.OwnerReferences = controlledBy(app)
+		}
+		r := newTestReconciler(t, policy)
+		if err := r.reconcileNetworkPolicy(context.Background(), app); err != nil {
+			t.Fatalf("reconcileNetworkPolicy() error = %v", err)
+		}
+		err := r.Get(context.Background(), client.ObjectKeyFromObject(policy), &networkingv1.NetworkPolicy{})
+		if deleted := apierrors.IsNotFound(err); deleted != owned {
+			t.Errorf("owned %v: NetworkPolicy deleted = %v, error = %v", owned, deleted, err)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// reconcileRBAC manages the Role and RoleBinding granting spec.rbacRules to the API ServiceAccount,
+// they are deleted once the rules are removed from the spec. The rules are never granted to the
+// namespace default account, every other pod of the namespace runs as it. A Role or RoleBinding
+// the operator did not create, or rules the API server refuses, are reported on the condition.
+func (r *SimpleapiReconciler) reconcileRBAC(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) (metav1.Condition, error) {
+	logger := log.FromContext(ctx)
+	name := getRoleName(SimpleAPIApp)
+	cond := metav1.Condition{
+		Type:   appsv1alpha1.ConditionRBACReady,
+		Status: metav1.ConditionTrue,
+		Reason: "Granted",
+		Message: fmt.Sprintf(
+			"rbacRules are granted to ServiceAccount %s through Role %s",
+			serviceAccountName(SimpleAPIApp),
+			name,
+		),
+		ObservedGeneration: SimpleAPIApp.Generation,
+	}
+	notGranted := func(reason, message string) metav1.Condition {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = reason
+		cond.Message = message
+		return cond
+	}
+
+	if len(SimpleAPIApp.Spec.RBACRules) == 0 || serviceAccountName(SimpleAPIApp) == "default" {
+		if err := r.deleteOwned(ctx, SimpleAPIApp, &rbacv1.RoleBinding{}, name); err != nil {
+			return cond, err
+		}
+		if err := r.deleteOwned(ctx, SimpleAPIApp, &rbacv1.Role{}, name); err != nil {
+			return cond, err
+		}
+		return notGranted("DefaultServiceAccount",
+			"rbacRules are not granted to the namespace default ServiceAccount, set serviceAccountSpec"), nil
+	}
+	if !r.WebhookEnabled {
+		return notGranted("WebhookDisabled",
+			"rbacRules are only granted while the validating webhook checks them, set ENABLE_WEBHOOKS=true"), nil
+	}
+
+	role := &rbacv1.Role{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: SimpleAPIApp.Namespace, Name: name}, role)
+	if errors.IsNotFound(err) {
+		role = r.constructRole(SimpleAPIApp)
+		if err := controllerutil.SetControllerReference(SimpleAPIApp, role, r.Scheme); err != nil {
+			return cond, err
+		}
+		err = r.Create(ctx, role)
+	} else if err != nil {
+		return cond, err
+	} else if !metav1.IsControlledBy(role, SimpleAPIApp) {
+		logger.Info("Role exists and is not managed by this Simpleapi, leaving it untouched", "Role", name)
+		return notGranted("NotOwned",
+			fmt.Sprintf("Role %s already exists and was not created by the operator", name)), nil
+	} else {
+		role.Rules = r.constructRole(SimpleAPIApp).Rules
+		err = r.Update(ctx, role)
+	}
+	if err != nil {
+		logger.Error(err, "Failed to apply Role", "Role", name)
+		return notGranted("Failed", fmt.Sprintf("Role %s: %v", name, err)), nil
+	}
+
+	roleBinding := &rbacv1.RoleBinding{}
+	err = r.Get(ctx, client.ObjectKey{Namespace: SimpleAPIApp.Namespace, Name: name}, roleBinding)
+	if errors.IsNotFound(err) {
+		roleBinding = r.constructRoleBinding(SimpleAPIApp)
+		if err := controllerutil.SetControllerReference(SimpleAPIApp, roleBinding, r.Scheme); err != nil {
+			return cond, err
+		}
+		err = r.Create(ctx, roleBinding)
+	} else if err != nil {
+		return cond, err
+	} else if !metav1.IsControlledBy(roleBinding, SimpleAPIApp) {
+		logger.Info("RoleBinding exists and is not managed by this Simpleapi, leaving it untouched", "RoleBinding", name)
+		return notGranted("NotOwned",
+			fmt.Sprintf("RoleBinding %s already exists and was not created by the operator", name)), nil
+	} else {
+		// roleRef is immutable and always points to the Role above, only the subjects can change
+		roleBinding.Subjects = r.constructRoleBinding(SimpleAPIApp).Subjects
+		err = r.Update(ctx, roleBinding)
+	}
+	if err != nil {
+		logger.Error(err, "Failed to apply RoleBinding", "RoleBinding", name)
+		return notGranted("Failed", fmt.Sprintf("RoleBinding %s: %v", name, err)), nil
+	}
+	return cond, nil
+}
+
+func (r *SimpleapiReconciler) constructRole(SimpleAPIApp *appsv1alpha1.Simpleapi) *rbacv1.Role {
+	return &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getRoleName(SimpleAPIApp),
+			Namespace: SimpleAPIApp.Namespace,
+			Labels: map[string]string{
+				simpleapiLabel: SimpleAPIApp.Name,
+			},
+		},
+		Rules: SimpleAPIApp.Spec.RBACRules,
+	}
+}
+
+func (r *SimpleapiReconciler) constructRoleBinding(
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getRoleName(SimpleAPIApp),
+			Namespace: SimpleAPIApp.Namespace,
+			Labels: map[string]string{
+				simpleapiLabel: SimpleAPIApp.Name,
+			},
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      rbacv1.ServiceAccountKind,
+				Name:      serviceAccountName(SimpleAPIApp),
+				Namespace: SimpleAPIApp.Namespace,
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     getRoleName(SimpleAPIApp),
+		},
+	}
+}
+
+// deleteOwned deletes the named object in the Simpleapi namespace if it exists and is
+// controlled by the Simpleapi, objects created by someone else are left alone
+func (r *SimpleapiReconciler) deleteOwned(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+	obj client.Object,
+	name string,
+) error {
+	err := r.Get(ctx, client.ObjectKey{Namespace: SimpleAPIApp.Namespace, Name: name}, obj)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(obj, SimpleAPIApp) {
+		return nil
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, obj))
+}
+
+func getRoleName(SimpleAPIApp *appsv1alpha1.Simpleapi) string {
+	return fmt.Sprintf("%s-role", SimpleAPIApp.Name)
+}
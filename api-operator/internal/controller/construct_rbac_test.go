@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestReconcileRBAC(t *testing.T) {
+	rules := []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}}
+	staleRules := []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}}
+	app := testSimpleapi()
+	role := func(refs []metav1.OwnerReference) *rbacv1.Role {
+		return &rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{Name: getRoleName(app), Namespace: app.Namespace, OwnerReferences: refs},
+			Rules:      staleRules,
+		}
+	}
+	roleBinding := func(refs []metav1.OwnerReference) *rbacv1.RoleBinding {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: getRoleName(app), Namespace: app.Namespace, OwnerReferences: refs},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "other", Namespace: app.Namespace}},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: getRoleName(app)},
+		}
+	}
+	tests := []struct {
+		name           string
+		rules          []rbacv1.PolicyRule
+		serviceAccount *appsv1alpha1.ServiceAccountSpec
+		webhook        bool
+		existing       []client.Object
+		// createErr is returned by the API server on create
+		createErr       error
+		wantStatus      metav1.ConditionStatus
+		wantReason      string
+		wantRules       []rbacv1.PolicyRule
+		wantSubject     string
+		wantRole        bool
+		wantRoleBinding bool
+	}{
+		{
+			name:            "rules granted to the ServiceAccount",
+			rules:           rules,
+			serviceAccount:  &appsv1alpha1.ServiceAccountSpec{Create: true, Name: "api"},
+			webhook:         true,
+			wantStatus:      metav1.ConditionTrue,
+			wantReason:      "Granted",
+			wantRules:       rules,
+			wantSubject:     "api",
+			wantRole:        true,
+			wantRoleBinding: true,
+		},
+		{
+			name:            "owned Role and RoleBinding are updated",
+			rules:           rules,
+			serviceAccount:  &appsv1alpha1.ServiceAccountSpec{Create: true, Name: "api"},
+			webhook:         true,
+			existing:        []client.Object{role(controlledBy(app)), roleBinding(controlledBy(app))},
+			wantStatus:      metav1.ConditionTrue,
+			wantReason:      "Granted",
+			wantRules:       rules,
+			wantSubject:     "api",
+			wantRole:        true,
+			wantRoleBinding: true,
+		},
+		{
+			name:           "foreign Role is left alone",
+			rules:          rules,
+			serviceAccount: &appsv1alpha1.ServiceAccountSpec{Create: true, Name: "api"},
+			webhook:        true,
+			existing:       []client.Object{role(nil)},
+			wantStatus:     metav1.ConditionFalse,
+			wantReason:     "NotOwned",
+			wantRules:      staleRules,
+			wantRole:       true,
+		},
+		{
+			name:            "foreign RoleBinding is left alone",
+			rules:           rules,
+			serviceAccount:  &appsv1alpha1.ServiceAccountSpec{Create: true, Name: "api"},
+			webhook:         true,
+			existing:        []client.Object{roleBinding(nil)},
+			wantStatus:      metav1.ConditionFalse,
+			wantReason:      "NotOwned",
+			wantRules:       rules,
+			wantSubject:     "other",
+			wantRole:        true,
+			wantRoleBinding: true,
+		},
+		{
+			name:           "refused Role is reported",
+			rules:          rules,
+			serviceAccount: &appsv1alpha1.ServiceAccountSpec{Create: true, Name: "api"},
+			webhook:        true,
+			createErr:      apierrors.NewForbidden(rbacv1.Resource("roles"), getRoleName(app), nil),
+			wantStatus:     metav1.ConditionFalse,
+			wantReason:     "Failed",
+		},
+		{
+			name:           "not granted without the webhook",
+			rules:          rules,
+			serviceAccount: &appsv1alpha1.ServiceAccountSpec{Create: true, Name: "api"},
+			wantStatus:     metav1.ConditionFalse,
+			wantReason:     "WebhookDisabled",
+		},
+		{
+			name:       "not granted to the default ServiceAccount",
+			rules:      rules,
+			webhook:    true,
+			existing:   []client.Object{role(controlledBy(app)), roleBinding(controlledBy(app))},
+			wantStatus: metav1.ConditionFalse,
+			wantReason: "DefaultServiceAccount",
+		},
+		{
+			name:           "removed rules delete the owned Role and RoleBinding",
+			serviceAccount: &appsv1alpha1.ServiceAccountSpec{Create: true, Name: "api"},
+			webhook:        true,
+			existing:       []client.Object{role(controlledBy(app)), roleBinding(controlledBy(app))},
+			wantStatus:     metav1.ConditionFalse,
+			wantReason:     "DefaultServiceAccount",
+		},
+		{
+			name:            "removed rules keep a foreign Role and RoleBinding",
+			serviceAccount:  &appsv1alpha1.ServiceAccountSpec{Create: true, Name: "api"},
+			webhook:         true,
+			existing:        []client.Object{role(nil), roleBinding(nil)},
+			wantStatus:      metav1.ConditionFalse,
+			wantReason:      "DefaultServiceAccount",
+			wantRules:       staleRules,
+			wantSubject:     "other",
+			wantRole:        true,
+			wantRoleBinding: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := app.DeepCopy()
+			app.Spec.RBACRules = tt.rules
+			app.Spec.ServiceAccountSpec = tt.serviceAccount
+			r := newTestReconciler(t, tt.existing...)
+			r.WebhookEnabled = tt.webhook
+			if tt.createErr != nil {
+				r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+					Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+						return tt.createErr
+					},
+				})
+			}
+
+			cond, err := r.reconcileRBAC(context.Background(), app)
+			if err != nil {
+				t.Fatalf("reconcileRBAC() error = %v", err)
+			}
+			if cond.Status != tt.wantStatus || cond.Reason != tt.wantReason {
+				t.Errorf("condition = %s/%s (%s), want %s/%s",
+					cond.Status, cond.Reason, cond.Message, tt.wantStatus, tt.wantReason)
+			}
+
+			key := client.ObjectKey{Namespace: app.Namespace, Name: getRoleName(app)}
+			gotRole := &rbacv1.Role{}
+			err = r.Get(context.Background(), key, gotRole)
+			if (err == nil) != tt.wantRole {
+				t.Fatalf("Role present = %v, want %v", err == nil, tt.wantRole)
+			}
+			if tt.wantRole && !equality.Semantic.DeepEqual(gotRole.Rules, tt.wantRules) {
+				t.Errorf("Role rules = %v, want %v", gotRole.Rules, tt.wantRules)
+			}
+			gotRoleBinding := &rbacv1.RoleBinding{}
+			err = r.Get(context.Background(), key, gotRoleBinding)
+			if (err == nil) != tt.wantRoleBinding {
+				t.Fatalf("RoleBinding present = %v, want %v", err == nil, tt.wantRoleBinding)
+			}
+			if tt.wantRoleBinding && gotRoleBinding.Subjects[0].Name != tt.wantSubject {
+				t.Errorf("RoleBinding subject = %s, want %s", gotRoleBinding.Subjects[0].Name, tt.wantSubject)
+			}
+		})
+	}
+}
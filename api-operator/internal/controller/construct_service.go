@@ -1,15 +1,21 @@
 package controller
 
 import (
+	"context"
 	"fmt"
-	"strings"
 
 	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+// servicePortName names the API port so ServiceMonitors can select it and routes target it
+const servicePortName string = "http"
+
 func (r *SimpleapiReconciler) constructService(
 	SimpleAPIApp appsv1alpha1.Simpleapi, timestamp int64,
 ) *corev1.Service {
@@ -17,8 +23,9 @@ func (r *SimpleapiReconciler) constructService(
 		Name:      serviceName(SimpleAPIApp.Spec.Version, SimpleAPIApp.Name),
 		Namespace: SimpleAPIApp.Namespace,
 		Labels: map[string]string{
-			"app":     SimpleAPIApp.Labels["app"],
-			"version": SimpleAPIApp.Spec.Version,
+			"app":          SimpleAPIApp.Labels["app"],
+			"version":      SimpleAPIApp.Spec.Version,
+			simpleapiLabel: SimpleAPIApp.Name,
 		},
 		Annotations: map[string]string{
 			"lastDeployedAt": fmt.Sprintf("%d", timestamp),
@@ -26,16 +33,11 @@ func (r *SimpleapiReconciler) constructService(
 	}
 	spec := corev1.ServiceSpec{
 		Selector: map[string]string{
-			"app":     SimpleAPIApp.Labels["app"],
-			"version": SimpleAPIApp.Spec.Version,
-		},
-		Ports: []corev1.ServicePort{
-			{
-				Port:       SimpleAPIApp.Spec.Port,
-				TargetPort: intstr.FromInt(int(SimpleAPIApp.Spec.Port)),
-				Protocol:   corev1.ProtocolTCP,
-			},
+			"app":          SimpleAPIApp.Labels["app"],
+			"version":      SimpleAPIApp.Spec.Version,
+			simpleapiLabel: SimpleAPIApp.Name,
 		},
+		Ports: servicePorts(&SimpleAPIApp),
 	}
 
 	svc := &corev1.Service{
@@ -49,5 +51,66 @@ func (r *SimpleapiReconciler) constructService(
 // returns a standardized Service name based on the version, important for replacor in utils is th make version at the end.
 // TODO maybe for improvement
 func serviceName(version string, serviceName string) string {
-	return fmt.Sprintf(serviceName+"-%s-svc", strings.ToLower(version))
+	return serviceNameFromDeploymentName(deploymentName(version, serviceName))
+}
+
+// reconcileVersionSelectors moves the Services of versions created before the simpleapi label
+// to a selector that includes it, so they stop selecting the pods of other Simpleapis sharing
+// the app label. The pod template of such a Deployment gets the label first and the Service
+// selector follows once the rollout replaced every pod without it.
+func (r *SimpleapiReconciler) reconcileVersionSelectors(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+	deployments []appsv1.Deployment,
+) error {
+	logger := log.FromContext(ctx)
+	for i := range deployments {
+		dep := &deployments[i]
+		if dep.Spec.Template.Labels[simpleapiLabel] != SimpleAPIApp.Name {
+			patch := client.MergeFrom(dep.DeepCopy())
+			if dep.Spec.Template.Labels == nil {
+				dep.Spec.Template.Labels = map[string]string{}
+			}
+			dep.Spec.Template.Labels[simpleapiLabel] = SimpleAPIApp.Name
+			logger.Info("Adding the simpleapi label to the pod template", "deployment", dep.Name)
+			if err := r.Patch(ctx, dep, patch); err != nil {
+				return err
+			}
+			continue
+		}
+		if !deploymentRolledOut(dep) {
+			continue
+		}
+
+		service := &corev1.Service{}
+		err := r.Get(ctx, client.ObjectKey{Namespace: dep.Namespace, Name: serviceNameFromDeploymentName(dep.Name)}, service)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if !metav1.IsControlledBy(service, SimpleAPIApp) || service.Spec.Selector[simpleapiLabel] == SimpleAPIApp.Name {
+			continue
+		}
+		if service.Labels == nil {
+			service.Labels = map[string]string{}
+		}
+		service.Labels[simpleapiLabel] = SimpleAPIApp.Name
+		service.Spec.Selector = map[string]string{
+			"app":          dep.Spec.Template.Labels["app"],
+			"version":      dep.Spec.Template.Labels["version"],
+			simpleapiLabel: SimpleAPIApp.Name,
+		}
+		logger.Info("Restricting the Service selector to this Simpleapi", "service", service.Name)
+		if err := r.Update(ctx, service); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deploymentRolledOut reports whether every pod of the Deployment runs its current template
+func deploymentRolledOut(dep *appsv1.Deployment) bool {
+	return dep.Status.ObservedGeneration >= dep.Generation &&
+		dep.Status.UpdatedReplicas == dep.Status.Replicas
 }
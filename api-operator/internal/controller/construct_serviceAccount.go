@@ -1,31 +1,144 @@
 package controller
 
 import (
+	"context"
+	"fmt"
+
 	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+// reconcileServiceAccount creates or updates the ServiceAccount when spec.serviceAccount.create is set.
+// An account that exists without being controlled by the Simpleapi is never adopted nor changed.
+func (r *SimpleapiReconciler) reconcileServiceAccount(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) (metav1.Condition, error) {
+	logger := log.FromContext(ctx)
+	name := serviceAccountName(SimpleAPIApp)
+	cond := metav1.Condition{
+		Type:               appsv1alpha1.ConditionServiceAccountReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Existing",
+		Message:            fmt.Sprintf("pods run as existing ServiceAccount %s", name),
+		ObservedGeneration: SimpleAPIApp.Generation,
+	}
+	spec := serviceAccountSpec(SimpleAPIApp)
+	if spec == nil || !spec.Create {
+		return cond, nil
+	}
+
+	sa := &corev1.ServiceAccount{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: SimpleAPIApp.Namespace, Name: name}, sa)
+	if errors.IsNotFound(err) {
+		sa = r.constructServiceAccount(SimpleAPIApp)
+		if err := controllerutil.SetControllerReference(SimpleAPIApp, sa, r.Scheme); err != nil {
+			return cond, err
+		}
+		if err := r.Create(ctx, sa); err != nil {
+			return cond, err
+		}
+		logger.Info("Successfully created new service account", "ServiceAccount", sa.Name)
+	} else if err != nil {
+		return cond, err
+	} else if !metav1.IsControlledBy(sa, SimpleAPIApp) && ownedBy(sa, SimpleAPIApp) {
+		// accounts created for the deprecated serviceAccount name carry a plain owner reference
+		if err := controllerutil.SetControllerReference(SimpleAPIApp, sa, r.Scheme); err != nil {
+			return cond, err
+		}
+		if err := r.Update(ctx, sa); err != nil {
+			return cond, err
+		}
+		logger.Info("Adopted service account", "ServiceAccount", sa.Name)
+	} else if !metav1.IsControlledBy(sa, SimpleAPIApp) {
+		logger.Info("Service Account exists and is not managed by this Simpleapi, leaving it untouched",
+			"ServiceAccount", sa.Name)
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "NotOwned"
+		cond.Message = fmt.Sprintf(
+			"ServiceAccount %s already exists and was not created by the operator, set create to false to use it",
+			name,
+		)
+		return cond, nil
+	} else {
+		newSa := r.constructServiceAccount(SimpleAPIApp)
+		sa.Labels = newSa.Labels
+		sa.Annotations = newSa.Annotations
+		sa.AutomountServiceAccountToken = newSa.AutomountServiceAccountToken
+		if err := r.Update(ctx, sa); err != nil {
+			return cond, err
+		}
+	}
+
+	cond.Reason = "Managed"
+	cond.Message = fmt.Sprintf("ServiceAccount %s is managed by the operator", name)
+	return cond, nil
+}
+
 func (r *SimpleapiReconciler) constructServiceAccount(
-	SimpleAPIApp appsv1alpha1.Simpleapi,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
 ) *corev1.ServiceAccount {
 	serviceAccount := &corev1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      SimpleAPIApp.Spec.ServiceAccountName,
+			Name:      serviceAccountName(SimpleAPIApp),
 			Namespace: SimpleAPIApp.Namespace,
 			Labels: map[string]string{
-				"app": SimpleAPIApp.Name,
-			},
-			// Optionally set owner references for garbage collection
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion: SimpleAPIApp.APIVersion,
-					Kind:       SimpleAPIApp.Kind,
-					Name:       SimpleAPIApp.Name,
-					UID:        SimpleAPIApp.UID,
-				},
+				"app":          SimpleAPIApp.Name,
+				simpleapiLabel: SimpleAPIApp.Name,
 			},
+			Annotations: serviceAccountSpec(SimpleAPIApp).Annotations,
 		},
+		AutomountServiceAccountToken: automountToken(SimpleAPIApp),
 	}
 	return serviceAccount
 }
+
+// serviceAccountSpec returns spec.serviceAccountSpec or, for the deprecated serviceAccount
+// name, an account of that name created by the operator
+func serviceAccountSpec(SimpleAPIApp *appsv1alpha1.Simpleapi) *appsv1alpha1.ServiceAccountSpec {
+	if SimpleAPIApp.Spec.ServiceAccountSpec != nil {
+		return SimpleAPIApp.Spec.ServiceAccountSpec
+	}
+	if SimpleAPIApp.Spec.ServiceAccountName != "" {
+		return &appsv1alpha1.ServiceAccountSpec{Create: true, Name: SimpleAPIApp.Spec.ServiceAccountName}
+	}
+	return nil
+}
+
+func ownedBy(obj metav1.Object, SimpleAPIApp *appsv1alpha1.Simpleapi) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == SimpleAPIApp.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceAccountName returns the ServiceAccount the pods run as
+func serviceAccountName(SimpleAPIApp *appsv1alpha1.Simpleapi) string {
+	sa := serviceAccountSpec(SimpleAPIApp)
+	switch {
+	case sa == nil:
+		return "default"
+	case sa.Name != "":
+		return sa.Name
+	case sa.Create:
+		return SimpleAPIApp.Name
+	default:
+		return "default"
+	}
+}
+
+func automountToken(SimpleAPIApp *appsv1alpha1.Simpleapi) *bool {
+	sa := serviceAccountSpec(SimpleAPIApp)
+	if sa == nil || sa.AutomountToken == nil {
+		return ptr.To(false)
+	}
+	return ptr.To(*sa.AutomountToken)
+}
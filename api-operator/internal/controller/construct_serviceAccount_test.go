@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestReconcileServiceAccount(t *testing.T) {
+	app := testSimpleapi()
+	// existing returns the ServiceAccount api with the given owner references
+	existing := func(refs []metav1.OwnerReference) *corev1.ServiceAccount {
+		return &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "api",
+				Namespace:       app.Namespace,
+				Annotations:     map[string]string{"team": "other"},
+				OwnerReferences: refs,
+			},
+		}
+	}
+	plainOwner := metav1.OwnerReference{
+		APIVersion: appsv1alpha1.GroupVersion.String(),
+		Kind:       "Simpleapi",
+		Name:       app.Name,
+		UID:        app.UID,
+	}
+	annotations := map[string]string{"team": "api"}
+	tests := []struct {
+		name            string
+		spec            *appsv1alpha1.ServiceAccountSpec
+		accountName     string
+		existing        *corev1.ServiceAccount
+		wantReason      string
+		wantStatus      metav1.ConditionStatus
+		wantControlled  bool
+		wantAnnotations map[string]string
+	}{
+		{
+			name:       "no spec uses the default account",
+			wantReason: "Existing",
+			wantStatus: metav1.ConditionTrue,
+		},
+		{
+			name:       "existing account is used as is",
+			spec:       &appsv1alpha1.ServiceAccountSpec{Name: "api"},
+			existing:   existing(nil),
+			wantReason: "Existing",
+			wantStatus: metav1.ConditionTrue,
+			// left untouched
+			wantAnnotations: map[string]string{"team": "other"},
+		},
+		{
+			name:            "created account",
+			spec:            &appsv1alpha1.ServiceAccountSpec{Create: true, Name: "api", Annotations: annotations},
+			wantReason:      "Managed",
+			wantStatus:      metav1.ConditionTrue,
+			wantControlled:  true,
+			wantAnnotations: annotations,
+		},
+		{
+			name:            "owned account is updated",
+			spec:            &appsv1alpha1.ServiceAccountSpec{Create: true, Name: "api", Annotations: annotations},
+			existing:        existing(controlledBy(app)),
+			wantReason:      "Managed",
+			wantStatus:      metav1.ConditionTrue,
+			wantControlled:  true,
+			wantAnnotations: annotations,
+		},
+		{
+			name:            "account of the deprecated serviceAccount name is adopted",
+			accountName:     "api",
+			existing:        existing([]metav1.OwnerReference{plainOwner}),
+			wantReason:      "Managed",
+			wantStatus:      metav1.ConditionTrue,
+			wantControlled:  true,
+			wantAnnotations: map[string]string{"team": "other"},
+		},
+		{
+			name:            "foreign account is not adopted",
+			spec:            &appsv1alpha1.ServiceAccountSpec{Create: true, Name: "api", Annotations: annotations},
+			existing:        existing(nil),
+			wantReason:      "NotOwned",
+			wantStatus:      metav1.ConditionFalse,
+			wantAnnotations: map[string]string{"team": "other"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := app.DeepCopy()
+			app.Spec.ServiceAccountSpec = tt.spec
+			app.Spec.ServiceAccountName = tt.accountName
+			objs := []client.Object{}
+			if tt.existing != nil {
+				objs = append(objs, tt.existing)
+			}
+			r := newTestReconciler(t, objs...)
+
+			cond, err := r.reconcileServiceAccount(context.Background(), app)
+			if err != nil {
+				t.Fatalf("reconcileServiceAccount() error = %v", err)
+			}
+			if cond.Status != tt.wantStatus || cond.Reason != tt.wantReason {
+				t.Errorf("condition = %s/%s (%s), want %s/%s",
+					cond.Status, cond.Reason, cond.Message, tt.wantStatus, tt.wantReason)
+			}
+
+			sa := &corev1.ServiceAccount{}
+			err = r.Get(context.Background(), client.ObjectKey{Namespace: app.Namespace, Name: "api"}, sa)
+			if apierrors.IsNotFound(err) {
+				if tt.wantControlled || tt.wantAnnotations != nil {
+					t.Error("ServiceAccount api not found")
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if got := metav1.IsControlledBy(sa, app); got != tt.wantControlled {
+				t.Errorf("ServiceAccount controlled = %v, want %v", got, tt.wantControlled)
+			}
+			if sa.Annotations["team"] != tt.wantAnnotations["team"] {
+				t.Errorf("ServiceAccount annotations = %v, want %v", sa.Annotations, tt.wantAnnotations)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// the Prometheus Operator types are not vendored, ServiceMonitors are handled as unstructured objects
+var serviceMonitorGVK = schema.GroupVersionKind{
+	Group:   "monitoring.coreos.com",
+	Version: "v1",
+	Kind:    "ServiceMonitor",
+}
+
+const defaultMetricsPath string = "/metrics"
+
+// reconcileServiceMonitor manages the ServiceMonitor, the returned condition is only
+// meaningful when monitoring is enabled
+func (r *SimpleapiReconciler) reconcileServiceMonitor(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) (metav1.Condition, error) {
+	cond := metav1.Condition{
+		Type:               appsv1alpha1.ConditionServiceMonitorReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Created",
+		Message:            "the ServiceMonitor selects every version Service",
+		ObservedGeneration: SimpleAPIApp.Generation,
+	}
+	enabled := SimpleAPIApp.Spec.Monitoring != nil && SimpleAPIApp.Spec.Monitoring.Enabled
+	if enabled && !r.APIs.ServiceMonitor {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "CRDNotInstalled"
+		cond.Message = "the monitoring.coreos.com CRDs were not found when the operator started"
+		return cond, nil
+	}
+	if !enabled {
+		if !r.APIs.ServiceMonitor {
+			return cond, nil
+		}
+		return cond, r.deleteOwned(ctx, SimpleAPIApp, newServiceMonitor(), getServiceMonitorName(SimpleAPIApp))
+	}
+
+	desired, err := r.constructServiceMonitor(SimpleAPIApp)
+	if err != nil {
+		return cond, err
+	}
+	serviceMonitor := newServiceMonitor()
+	err = r.Get(
+		ctx,
+		client.ObjectKey{Namespace: SimpleAPIApp.Namespace, Name: getServiceMonitorName(SimpleAPIApp)},
+		serviceMonitor,
+	)
+	if errors.IsNotFound(err) {
+		if err := controllerutil.SetControllerReference(SimpleAPIApp, desired, r.Scheme); err != nil {
+			return cond, err
+		}
+		return cond, r.Create(ctx, desired)
+	} else if err != nil {
+		return cond, err
+	}
+	serviceMonitor.Object["spec"] = desired.Object["spec"]
+	if err := controllerutil.SetControllerReference(SimpleAPIApp, serviceMonitor, r.Scheme); err != nil {
+		return cond, err
+	}
+	return cond, r.Update(ctx, serviceMonitor)
+}
+
+// constructServiceMonitor selects the Services of all versions and copies their version label
+// onto the scraped series so canary and stable metrics can be split
+func (r *SimpleapiReconciler) constructServiceMonitor(
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) (*unstructured.Unstructured, error) {
+	monitoring := SimpleAPIApp.Spec.Monitoring
+
+	endpoint := map[string]interface{}{
+		"port": servicePortName,
+		"path": defaultMetricsPath,
+	}
+	if monitoring.Port != "" {
+		endpoint["port"] = monitoring.Port
+	}
+	if monitoring.Path != "" {
+		endpoint["path"] = monitoring.Path
+	}
+	if monitoring.Interval != "" {
+		endpoint["interval"] = monitoring.Interval
+	}
+	if len(monitoring.Relabelings) > 0 {
+		relabelings := make([]interface{}, len(monitoring.Relabelings))
+		for i := range monitoring.Relabelings {
+			relabeling, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&monitoring.Relabelings[i])
+			if err != nil {
+				return nil, err
+			}
+			relabelings[i] = relabeling
+		}
+		endpoint["relabelings"] = relabelings
+	}
+
+	serviceMonitor := newServiceMonitor()
+	serviceMonitor.SetName(getServiceMonitorName(SimpleAPIApp))
+	serviceMonitor.SetNamespace(SimpleAPIApp.Namespace)
+	serviceMonitor.SetLabels(map[string]string{
+		"app":          SimpleAPIApp.Labels["app"],
+		simpleapiLabel: SimpleAPIApp.Name,
+	})
+	serviceMonitor.Object["spec"] = map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": map[string]interface{}{
+				simpleapiLabel: SimpleAPIApp.Name,
+			},
+		},
+		"namespaceSelector": map[string]interface{}{
+			"matchNames": []interface{}{SimpleAPIApp.Namespace},
+		},
+		"targetLabels": []interface{}{"version"},
+		"endpoints":    []interface{}{endpoint},
+	}
+	return serviceMonitor, nil
+}
+
+func newServiceMonitor() *unstructured.Unstructured {
+	serviceMonitor := &unstructured.Unstructured{}
+	serviceMonitor.SetGroupVersionKind(serviceMonitorGVK)
+	return serviceMonitor
+}
+
+func getServiceMonitorName(SimpleAPIApp *appsv1alpha1.Simpleapi) string {
+	return fmt.Sprintf("%s-monitor", SimpleAPIApp.Name)
+}
+
+// monitoredPort returns the port the ServiceMonitor scrapes, the routed port when the
+// named port is not declared
+func monitoredPort(SimpleAPIApp *appsv1alpha1.Simpleapi) appsv1alpha1.PortSpec {
+	name := servicePortName
+	if SimpleAPIApp.Spec.Monitoring != nil && SimpleAPIApp.Spec.Monitoring.Port != "" {
+		name = SimpleAPIApp.Spec.Monitoring.Port
+	}
+	for _, port := range versionPorts(SimpleAPIApp) {
+		if port.Name == name {
+			return port
+		}
+	}
+	return routedPort(SimpleAPIApp)
+}
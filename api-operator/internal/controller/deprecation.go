@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// deprecatedVersionLabel marks the Ingress that serves a deprecated version
+	deprecatedVersionLabel string = "apps.api.test/deprecated-version"
+	// customHeadersAnnotation points ingress-nginx to the ConfigMap of response headers, the header
+	// names have to be listed in global-allowed-response-headers of the controller
+	customHeadersAnnotation string = "nginx.ingress.kubernetes.io/custom-headers"
+)
+
+func versionSpec(SimpleAPIApp *appsv1alpha1.Simpleapi, version string) *appsv1alpha1.VersionSpec {
+	for i := range SimpleAPIApp.Spec.Versions {
+		if SimpleAPIApp.Spec.Versions[i].Version == version {
+			return &SimpleAPIApp.Spec.Versions[i]
+		}
+	}
+	return nil
+}
+
+// withoutSunsetVersions drops the deployments of versions whose sunset time has passed,
+// so they are no longer routed nor retained, except the spec version
+func withoutSunsetVersions(
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+	deployments []appsv1.Deployment,
+	now time.Time,
+) []appsv1.Deployment {
+	active := []appsv1.Deployment{}
+	for _, dep := range deployments {
+		version := dep.Labels["version"]
+		spec := versionSpec(SimpleAPIApp, version)
+		if version != SimpleAPIApp.Spec.Version && spec != nil && spec.SunsetAt != nil &&
+			!now.Before(spec.SunsetAt.Time) {
+			continue
+		}
+		active = append(active, dep)
+	}
+	return active
+}
+
+// deprecateBackends sets the Deprecation and Sunset response headers on the backends of
+// deprecated versions
+func deprecateBackends(SimpleAPIApp *appsv1alpha1.Simpleapi, backends []routeBackend, now time.Time) {
+	for i := range backends {
+		spec := versionSpec(SimpleAPIApp, backends[i].Version)
+		if spec == nil || spec.DeprecatedAt == nil || now.Before(spec.DeprecatedAt.Time) {
+			continue
+		}
+		backends[i].Headers = []responseHeader{
+			{Name: "Deprecation", Value: fmt.Sprintf("@%d", spec.DeprecatedAt.Unix())},
+		}
+		if spec.SunsetAt != nil {
+			backends[i].Headers = append(backends[i].Headers, responseHeader{
+				Name:  "Sunset",
+				Value: spec.SunsetAt.UTC().Format(http.TimeFormat),
+			})
+		}
+	}
+}
+
+// nextVersionEvent returns how long until the next deprecation or sunset time, zero when
+// none is scheduled
+func nextVersionEvent(SimpleAPIApp *appsv1alpha1.Simpleapi, now time.Time) time.Duration {
+	var next time.Duration
+	for _, spec := range SimpleAPIApp.Spec.Versions {
+		for _, t := range []*time.Time{timeOrNil(spec.DeprecatedAt), timeOrNil(spec.SunsetAt)} {
+			if t != nil && now.Before(*t) {
+				next = minRequeue(next, t.Sub(now))
+			}
+		}
+	}
+	return next
+}
+
+// deprecationHeaders is the data of the custom headers ConfigMap, header names to values
+func deprecationHeaders(headers []responseHeader) map[string]string {
+	data := make(map[string]string, len(headers))
+	for _, header := range headers {
+		data[header.Name] = header.Value
+	}
+	return data
+}
+
+func timeOrNil(t *metav1.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	return &t.Time
+}
+
+func getDeprecatedIngressName(SimpleAPIApp *appsv1alpha1.Simpleapi, version string) string {
+	return dnsSafeName(fmt.Sprintf("%s-%s", getIngressName(SimpleAPIApp), version), maxNameLength)
+}
@@ -0,0 +1,272 @@
+package controller
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// deprecationTime is the reference time of the deprecation tests
+var deprecationTime = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// deprecatedSimpleapi returns a Simpleapi at v3 where v1 is sunset and v2 is deprecated
+// with a sunset a month later
+func deprecatedSimpleapi() *appsv1alpha1.Simpleapi {
+	at := func(d time.Duration) *metav1.Time { return &metav1.Time{Time: deprecationTime.Add(d)} }
+	app := testSimpleapi()
+	app.Spec.Version = "v3"
+	app.Spec.Versions = []appsv1alpha1.VersionSpec{
+		{Version: "v1", DeprecatedAt: at(-60 * 24 * time.Hour), SunsetAt: at(-time.Hour)},
+		{Version: "v2", DeprecatedAt: at(-24 * time.Hour), SunsetAt: at(30 * 24 * time.Hour)},
+		{Version: "v3", DeprecatedAt: at(2 * time.Hour)},
+	}
+	return app
+}
+
+func TestWithoutSunsetVersions(t *testing.T) {
+	deployments := func(versions ...string) []appsv1.Deployment {
+		deps := []appsv1.Deployment{}
+		for _, version := range versions {
+			deps = append(deps, appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"version": version}},
+			})
+		}
+		return deps
+	}
+	tests := []struct {
+		name        string
+		specVersion string
+		want        []string
+	}{
+		{name: "sunset version dropped", specVersion: "v3", want: []string{"v2", "v3", "v4"}},
+		{name: "sunset spec version kept", specVersion: "v1", want: []string{"v1", "v2", "v3", "v4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := deprecatedSimpleapi()
+			app.Spec.Version = tt.specVersion
+			got := []string{}
+			for _, dep := range withoutSunsetVersions(app, deployments("v1", "v2", "v3", "v4"), deprecationTime) {
+				got = append(got, dep.Labels["version"])
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("withoutSunsetVersions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeprecateBackends(t *testing.T) {
+	app := deprecatedSimpleapi()
+	backends := []routeBackend{
+		{Version: "v2", Path: "/api/v2"},
+		{Version: "v3", Path: "/api/v3"},
+		{Version: "v4", Path: "/api/v4"},
+	}
+	deprecateBackends(app, backends, deprecationTime)
+
+	want := map[string][]responseHeader{
+		"v2": {
+			{Name: "Deprecation", Value: "@1748692800"},
+			{Name: "Sunset", Value: "Tue, 01 Jul 2025 12:00:00 GMT"},
+		},
+		// v3 is only deprecated in two hours, v4 has no settings
+		"v3": nil,
+		"v4": nil,
+	}
+	for _, backend := range backends {
+		if !slices.Equal(backend.Headers, want[backend.Version]) {
+			t.Errorf("version %s headers = %v, want %v", backend.Version, backend.Headers, want[backend.Version])
+		}
+	}
+}
+
+func TestNextVersionEvent(t *testing.T) {
+	app := deprecatedSimpleapi()
+	if got := nextVersionEvent(app, deprecationTime); got != 2*time.Hour {
+		t.Errorf("nextVersionEvent() = %v, want the deprecation of v3 in 2h", got)
+	}
+	if got := nextVersionEvent(app, deprecationTime.Add(60*24*time.Hour)); got != 0 {
+		t.Errorf("nextVersionEvent() after every event = %v, want 0", got)
+	}
+}
+
+func TestReconcileIngressDeprecated(t *testing.T) {
+	app := deprecatedSimpleapi()
+	app.Spec.IngressType = "ingress"
+	app.Spec.Port = 8080
+	deprecatedName := getDeprecatedIngressName(app, "v2")
+	backends := func() []routeBackend {
+		backends := []routeBackend{{Version: "v2", Path: "/api/v2"}, {Version: "v3", Path: "/api/v3"}}
+		deprecateBackends(app, backends, deprecationTime)
+		return backends
+	}
+	staleIngress := func(version string) *networkingv1.Ingress {
+		return &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            getDeprecatedIngressName(app, version),
+				Namespace:       app.Namespace,
+				Labels:          map[string]string{simpleapiLabel: app.Name, deprecatedVersionLabel: version},
+				OwnerReferences: controlledBy(app),
+			},
+		}
+	}
+	staleHeaders := func(version string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            getDeprecatedIngressName(app, version),
+				Namespace:       app.Namespace,
+				Labels:          map[string]string{simpleapiLabel: app.Name, deprecatedVersionLabel: version},
+				OwnerReferences: controlledBy(app),
+			},
+		}
+	}
+	tests := []struct {
+		name     string
+		backends []routeBackend
+		existing []client.Object
+		// failCreate makes the API server refuse objects of the type
+		failCreate     client.Object
+		wantMainPaths  []string
+		wantDeprecated []string
+		// wantHeaders is whether the headers ConfigMap of v2 exists afterwards
+		wantHeaders   bool
+		wantCondition metav1.ConditionStatus
+	}{
+		{
+			name:           "deprecated version served by its own Ingress",
+			backends:       backends(),
+			wantMainPaths:  []string{"/api/v3"},
+			wantDeprecated: []string{"v2"},
+			wantHeaders:    true,
+			wantCondition:  metav1.ConditionTrue,
+		},
+		{
+			name:          "refused Ingress falls back to the main Ingress",
+			backends:      backends(),
+			failCreate:    &networkingv1.Ingress{},
+			wantMainPaths: []string{"/api/v2", "/api/v3"},
+			wantHeaders:   true,
+			wantCondition: metav1.ConditionFalse,
+		},
+		{
+			name:          "refused headers fall back to the main Ingress",
+			backends:      backends(),
+			failCreate:    &corev1.ConfigMap{},
+			wantMainPaths: []string{"/api/v2", "/api/v3"},
+			wantCondition: metav1.ConditionFalse,
+		},
+		{
+			name: "foreign headers ConfigMap is not adopted",
+			existing: []client.Object{&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: deprecatedName, Namespace: app.Namespace},
+			}},
+			backends:      backends(),
+			wantMainPaths: []string{"/api/v2", "/api/v3"},
+			wantHeaders:   true,
+			wantCondition: metav1.ConditionFalse,
+		},
+		{
+			name:          "Ingress of a version no longer deprecated is removed",
+			backends:      []routeBackend{{Version: "v3", Path: "/api/v3"}},
+			existing:      []client.Object{staleIngress("v2"), staleHeaders("v2")},
+			wantMainPaths: []string{"/api/v3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReconciler(t, tt.existing...)
+			if tt.failCreate != nil {
+				r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+					Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+						if obj.GetName() == deprecatedName && reflect.TypeOf(obj) == reflect.TypeOf(tt.failCreate) {
+							return apierrors.NewBadRequest("denied by the admission webhook")
+						}
+						return c.Create(ctx, obj, opts...)
+					},
+				})
+			}
+
+			cond, err := r.reconcileIngress(context.Background(), tt.backends, app.Namespace, app)
+			if err != nil {
+				t.Fatalf("reconcileIngress() error = %v", err)
+			}
+			if tt.wantCondition == "" && cond != nil {
+				t.Errorf("condition = %+v, want none", cond)
+			} else if tt.wantCondition != "" && (cond == nil || cond.Status != tt.wantCondition) {
+				t.Errorf("condition = %+v, want %s", cond, tt.wantCondition)
+			}
+
+			main := &networkingv1.Ingress{}
+			if err := r.Get(
+				context.Background(),
+				client.ObjectKey{Namespace: app.Namespace, Name: getIngressName(app)},
+				main,
+			); err != nil {
+				t.Fatal(err)
+			}
+			if got := ingressPaths(main); !slices.Equal(got, tt.wantMainPaths) {
+				t.Errorf("main Ingress paths = %v, want %v", got, tt.wantMainPaths)
+			}
+
+			var ingresses networkingv1.IngressList
+			if err := r.List(context.Background(), &ingresses, client.HasLabels{deprecatedVersionLabel}); err != nil {
+				t.Fatal(err)
+			}
+			deprecated := []string{}
+			for _, ingress := range ingresses.Items {
+				version := ingress.Labels[deprecatedVersionLabel]
+				deprecated = append(deprecated, version)
+				if got := ingress.Annotations[customHeadersAnnotation]; got != app.Namespace+"/"+ingress.Name {
+					t.Errorf("version %s custom-headers = %q, want its ConfigMap", version, got)
+				}
+				headers := &corev1.ConfigMap{}
+				if err := r.Get(context.Background(), client.ObjectKeyFromObject(&ingress), headers); err != nil {
+					t.Fatalf("headers of version %s: %v", version, err)
+				}
+				want := map[string]string{"Deprecation": "@1748692800", "Sunset": "Tue, 01 Jul 2025 12:00:00 GMT"}
+				if !maps.Equal(headers.Data, want) {
+					t.Errorf("version %s headers = %v, want %v", version, headers.Data, want)
+				}
+			}
+			if !slices.Equal(deprecated, tt.wantDeprecated) {
+				t.Errorf("deprecated Ingresses = %v, want %v", deprecated, tt.wantDeprecated)
+			}
+
+			err = r.Get(
+				context.Background(),
+				client.ObjectKey{Namespace: app.Namespace, Name: deprecatedName},
+				&corev1.ConfigMap{},
+			)
+			if err != nil && !apierrors.IsNotFound(err) {
+				t.Fatal(err)
+			}
+			if present := err == nil; present != tt.wantHeaders {
+				t.Errorf("headers ConfigMap present = %v, want %v", present, tt.wantHeaders)
+			}
+		})
+	}
+}
+
+// ingressPaths returns the paths of every rule of the Ingress
+func ingressPaths(ingress *networkingv1.Ingress) []string {
+	paths := []string{}
+	for _, rule := range ingress.Spec.Rules {
+		for _, path := range rule.HTTP.Paths {
+			paths = append(paths, path.Path)
+		}
+	}
+	return paths
+}
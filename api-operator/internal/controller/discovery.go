@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+)
+
+// DiscoveredAPIs records which optional CRDs were installed when the manager started.
+// Watches and routing modes that depend on a missing CRD are disabled.
+type DiscoveredAPIs struct {
+	HTTPRoute      bool
+	GRPCRoute      bool
+	TCPRoute       bool
+	TLSRoute       bool
+	ServiceMonitor bool
+}
+
+// DiscoverAPIs queries the API server discovery for the optional CRDs the operator can use
+func DiscoverAPIs(cfg *rest.Config) (DiscoveredAPIs, error) {
+	apis := DiscoveredAPIs{}
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return apis, err
+	}
+
+	apis.HTTPRoute, err = hasResource(dc, gatewayv1.GroupVersion.String(), "HTTPRoute")
+	if err != nil {
+		return apis, err
+	}
+	apis.GRPCRoute, err = hasResource(dc, gatewayv1.GroupVersion.String(), "GRPCRoute")
+	if err != nil {
+		return apis, err
+	}
+	// TCPRoute and TLSRoute are only installed with the Gateway API experimental channel
+	apis.TCPRoute, err = hasResource(dc, gatewayv1alpha2.GroupVersion.String(), "TCPRoute")
+	if err != nil {
+		return apis, err
+	}
+	apis.TLSRoute, err = hasResource(dc, gatewayv1alpha2.GroupVersion.String(), "TLSRoute")
+	if err != nil {
+		return apis, err
+	}
+	apis.ServiceMonitor, err = hasResource(dc, serviceMonitorGVK.GroupVersion().String(), serviceMonitorGVK.Kind)
+	if err != nil {
+		return apis, err
+	}
+	return apis, nil
+}
+
+func hasResource(dc discovery.DiscoveryInterface, groupVersion string, kind string) (bool, error) {
+	resources, err := dc.ServerResourcesForGroupVersion(groupVersion)
+	if errors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	for _, res := range resources.APIResources {
+		if res.Kind == kind {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// hasRoute reports whether the Gateway API route kind was found
+func (a DiscoveredAPIs) hasRoute(kind string) bool {
+	switch kind {
+	case "HTTPRoute":
+		return a.HTTPRoute
+	case "GRPCRoute":
+		return a.GRPCRoute
+	case "TCPRoute":
+		return a.TCPRoute
+	case "TLSRoute":
+		return a.TLSRoute
+	}
+	return false
+}
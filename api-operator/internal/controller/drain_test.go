@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestCleanupOldDeploymentsDrain(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	retireAt := func(d time.Duration) string {
+		return now.Add(d).UTC().Format(time.RFC3339)
+	}
+	tests := []struct {
+		name         string
+		drainSeconds *int32
+		// retireAt of the pruned versions v1 and v2, empty when they are not draining yet
+		retireAt    map[string]string
+		wantRetire  map[string]string
+		wantDeleted []string
+		wantRequeue time.Duration
+	}{
+		{
+			name:         "drainSeconds sets the retire time",
+			drainSeconds: ptr.To[int32](120),
+			wantRetire:   map[string]string{"v1": retireAt(2 * time.Minute), "v2": retireAt(2 * time.Minute)},
+			wantRequeue:  2 * time.Minute,
+		},
+		{
+			name:         "zero drainSeconds deletes right away",
+			drainSeconds: ptr.To[int32](0),
+			wantDeleted:  []string{"v1", "v2"},
+		},
+		{
+			name:        "requeued for the earliest retire time",
+			retireAt:    map[string]string{"v1": retireAt(50 * time.Second), "v2": retireAt(10 * time.Second)},
+			wantRetire:  map[string]string{"v1": retireAt(50 * time.Second), "v2": retireAt(10 * time.Second)},
+			wantRequeue: 10 * time.Second,
+		},
+		{
+			name:        "only the drained version is deleted",
+			retireAt:    map[string]string{"v1": retireAt(-time.Second), "v2": retireAt(10 * time.Second)},
+			wantRetire:  map[string]string{"v2": retireAt(10 * time.Second)},
+			wantDeleted: []string{"v1"},
+			wantRequeue: 10 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testSimpleapi()
+			app.Spec.Version = "v3"
+			app.Spec.DrainSeconds = tt.drainSeconds
+			objs := []client.Object{}
+			deployments := []appsv1.Deployment{}
+			for _, version := range []string{"v1", "v2", "v3"} {
+				dep := &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            deploymentName(version, app.Name),
+						Namespace:       app.Namespace,
+						UID:             types.UID("dep-" + version),
+						Labels:          map[string]string{"app": "demo", "version": version},
+						OwnerReferences: controlledBy(app),
+					},
+				}
+				if tt.retireAt[version] != "" {
+					dep.Annotations = map[string]string{retireAtAnnotation: tt.retireAt[version]}
+				}
+				objs = append(objs, dep, &corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            serviceName(version, app.Name),
+						Namespace:       app.Namespace,
+						OwnerReferences: controlledBy(app),
+					},
+				})
+				deployments = append(deployments, *dep)
+			}
+			r := newTestReconciler(t, objs...)
+
+			requeue, _, err := r.cleanupOldDeployments(context.Background(), app, deployments, []string{"v3"}, now)
+			if err != nil {
+				t.Fatalf("cleanupOldDeployments() error = %v", err)
+			}
+			if requeue != tt.wantRequeue {
+				t.Errorf("cleanupOldDeployments() requeue = %v, want %v", requeue, tt.wantRequeue)
+			}
+			deleted := map[string]bool{}
+			for _, version := range tt.wantDeleted {
+				deleted[version] = true
+			}
+			for _, version := range []string{"v1", "v2", "v3"} {
+				dep := &appsv1.Deployment{}
+				err := r.Get(
+					context.Background(),
+					client.ObjectKey{Namespace: app.Namespace, Name: deploymentName(version, app.Name)},
+					dep,
+				)
+				if present := err == nil; present == deleted[version] {
+					t.Errorf("version %s present = %v, want %v", version, present, !deleted[version])
+					continue
+				}
+				if got := dep.Annotations[retireAtAnnotation]; !deleted[version] && got != tt.wantRetire[version] {
+					t.Errorf("version %s retire-at = %q, want %q", version, got, tt.wantRetire[version])
+				}
+			}
+		})
+	}
+}
+
+func TestDrainPodSpec(t *testing.T) {
+	app := testSimpleapi()
+	app.Spec.Port = 8080
+	app.Spec.StartupProbe = &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/healthz"}},
+	}
+	app.Spec.PreStop = &corev1.LifecycleHandler{
+		Exec: &corev1.ExecAction{Command: []string{"sleep", "10"}},
+	}
+	app.Spec.TerminationGracePeriodSeconds = ptr.To[int64](60)
+	deployment, err := newTestReconciler(t).constructDeployment(*app, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := deployment.Spec.Template.Spec
+	if ptr.Deref(spec.TerminationGracePeriodSeconds, 0) != 60 {
+		t.Errorf("terminationGracePeriodSeconds = %v, want 60", spec.TerminationGracePeriodSeconds)
+	}
+	lifecycle := spec.Containers[0].Lifecycle
+	if lifecycle == nil || lifecycle.PreStop != app.Spec.PreStop {
+		t.Errorf("container lifecycle = %+v, want the spec preStop", lifecycle)
+	}
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"testing"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+)
+
+// newTestReconciler returns a reconciler on a fake client holding objs, with every optional API installed
+func newTestReconciler(t *testing.T, objs ...client.Object) *SimpleapiReconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme,
+		appsv1alpha1.AddToScheme,
+		gatewayv1.Install,
+		gatewayv1alpha2.Install,
+	} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&appsv1alpha1.Simpleapi{}).
+		Build()
+	return &SimpleapiReconciler{
+		Client: c,
+		Scheme: scheme,
+		APIs: DiscoveredAPIs{
+			HTTPRoute: true,
+			GRPCRoute: true,
+			TCPRoute:  true,
+			TLSRoute:  true,
+		},
+	}
+}
+
+// testSimpleapi returns a Simpleapi named demo in the default namespace
+func testSimpleapi() *appsv1alpha1.Simpleapi {
+	return &appsv1alpha1.Simpleapi{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: appsv1alpha1.GroupVersion.String(),
+			Kind:       "Simpleapi",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "demo",
+			Namespace: "default",
+			UID:       "demo-uid",
+			Labels:    map[string]string{"app": "demo"},
+		},
+		Spec: appsv1alpha1.SimpleapiSpec{
+			Version: "v1",
+			Image:   "demo:{version}",
+		},
+	}
+}
+
+// controlledBy returns the controller reference of SimpleAPIApp for objects owned by it in tests
+func controlledBy(SimpleAPIApp *appsv1alpha1.Simpleapi) []metav1.OwnerReference {
+	return []metav1.OwnerReference{*metav1.NewControllerRef(SimpleAPIApp, appsv1alpha1.GroupVersion.WithKind("Simpleapi"))}
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// checkAllowedRoutes looks up every parent Gateway of the generated route and verifies that
+// at least one matching listener admits its kind from the Simpleapi namespace.
+// Attaching to a Gateway in another namespace is governed by the listener allowedRoutes,
+// a ReferenceGrant is not involved for parentRefs.
+func (r *SimpleapiReconciler) checkAllowedRoutes(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) (metav1.Condition, error) {
+	var routeNamespace *corev1.Namespace
+	forbidden := []string{}
+
+	for _, ref := range constructParentRefs(SimpleAPIApp) {
+		gwNamespace := SimpleAPIApp.Namespace
+		if ref.Namespace != nil {
+			gwNamespace = string(*ref.Namespace)
+		}
+		parent := gwNamespace + "/" + string(ref.Name)
+		if ref.SectionName != nil {
+			parent += "/" + string(*ref.SectionName)
+		}
+
+		gateway := &gatewayv1.Gateway{}
+		err := r.Get(ctx, client.ObjectKey{Namespace: gwNamespace, Name: string(ref.Name)}, gateway)
+		if errors.IsNotFound(err) {
+			forbidden = append(forbidden, parent+" (gateway not found)")
+			continue
+		} else if err != nil {
+			return metav1.Condition{}, err
+		}
+
+		allowed := false
+		for _, listener := range gateway.Spec.Listeners {
+			if ref.SectionName != nil && listener.Name != *ref.SectionName {
+				continue
+			}
+			if ref.Port != nil && listener.Port != *ref.Port {
+				continue
+			}
+			if !listenerAllowsKind(listener, routeKind(SimpleAPIApp)) {
+				continue
+			}
+			from := gatewayv1.NamespacesFromSame
+			if listener.AllowedRoutes != nil && listener.AllowedRoutes.Namespaces != nil &&
+				listener.AllowedRoutes.Namespaces.From != nil {
+				from = *listener.AllowedRoutes.Namespaces.From
+			}
+
+			switch from {
+			case gatewayv1.NamespacesFromAll:
+				allowed = true
+			case gatewayv1.NamespacesFromSame:
+				allowed = gwNamespace == SimpleAPIApp.Namespace
+			case gatewayv1.NamespacesFromSelector:
+				if listener.AllowedRoutes.Namespaces.Selector == nil {
+					continue
+				}
+				if routeNamespace == nil {
+					routeNamespace = &corev1.Namespace{}
+					if err := r.Get(ctx, client.ObjectKey{Name: SimpleAPIApp.Namespace}, routeNamespace); err != nil {
+						return metav1.Condition{}, err
+					}
+				}
+				selector, err := metav1.LabelSelectorAsSelector(listener.AllowedRoutes.Namespaces.Selector)
+				if err != nil {
+					continue
+				}
+				allowed = selector.Matches(labels.Set(routeNamespace.Labels))
+			}
+			if allowed {
+				break
+			}
+		}
+		if !allowed {
+			forbidden = append(forbidden, parent)
+		}
+	}
+
+	if len(forbidden) > 0 {
+		return metav1.Condition{
+			Type:   appsv1alpha1.ConditionRouteAllowed,
+			Status: metav1.ConditionFalse,
+			Reason: "NotAllowedByListeners",
+			Message: fmt.Sprintf(
+				"namespace %s is not allowed by the listeners of %s",
+				SimpleAPIApp.Namespace,
+				strings.Join(forbidden, ", "),
+			),
+			ObservedGeneration: SimpleAPIApp.Generation,
+		}, nil
+	}
+	return metav1.Condition{
+		Type:               appsv1alpha1.ConditionRouteAllowed,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Allowed",
+		Message:            "all parent Gateways allow the " + routeKind(SimpleAPIApp) + " namespace",
+		ObservedGeneration: SimpleAPIApp.Generation,
+	}, nil
+}
+
+// routeKind is the Gateway API route kind generated for the ingressType
+func routeKind(SimpleAPIApp *appsv1alpha1.Simpleapi) string {
+	switch SimpleAPIApp.Spec.IngressType {
+	case "grpcroute":
+		return "GRPCRoute"
+	case "tcproute":
+		return "TCPRoute"
+	case "tlsroute":
+		return "TLSRoute"
+	}
+	return "HTTPRoute"
+}
+
+// protocolRouteKinds are the route kinds a listener accepts by default for its protocol
+var protocolRouteKinds = map[gatewayv1.ProtocolType][]string{
+	gatewayv1.HTTPProtocolType:  {"HTTPRoute", "GRPCRoute"},
+	gatewayv1.HTTPSProtocolType: {"HTTPRoute", "GRPCRoute"},
+	gatewayv1.TCPProtocolType:   {"TCPRoute"},
+	gatewayv1.TLSProtocolType:   {"TLSRoute"},
+}
+
+// listenerAllowsKind reports whether the listener accepts the route kind of the Gateway API
+// group, a listener without explicit kinds accepts the kinds matching its protocol
+func listenerAllowsKind(listener gatewayv1.Listener, kind string) bool {
+	if listener.AllowedRoutes == nil || len(listener.AllowedRoutes.Kinds) == 0 {
+		return slices.Contains(protocolRouteKinds[listener.Protocol], kind)
+	}
+	for _, k := range listener.AllowedRoutes.Kinds {
+		group := gatewayv1.GroupName
+		if k.Group != nil {
+			group = string(*k.Group)
+		}
+		if group == gatewayv1.GroupName && string(k.Kind) == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/utils/ptr"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestListenerAllowsKind(t *testing.T) {
+	kinds := func(kinds ...gatewayv1.RouteGroupKind) *gatewayv1.AllowedRoutes {
+		return &gatewayv1.AllowedRoutes{Kinds: kinds}
+	}
+	tests := []struct {
+		name     string
+		listener gatewayv1.Listener
+		kind     string
+		want     bool
+	}{
+		{"http default HTTPRoute", gatewayv1.Listener{Protocol: gatewayv1.HTTPProtocolType}, "HTTPRoute", true},
+		{"https default GRPCRoute", gatewayv1.Listener{Protocol: gatewayv1.HTTPSProtocolType}, "GRPCRoute", true},
+		{"http default no TCPRoute", gatewayv1.Listener{Protocol: gatewayv1.HTTPProtocolType}, "TCPRoute", false},
+		{"http default no TLSRoute", gatewayv1.Listener{Protocol: gatewayv1.HTTPProtocolType}, "TLSRoute", false},
+		{"tcp default TCPRoute", gatewayv1.Listener{Protocol: gatewayv1.TCPProtocolType}, "TCPRoute", true},
+		{"tls default TLSRoute", gatewayv1.Listener{Protocol: gatewayv1.TLSProtocolType}, "TLSRoute", true},
+		{"tls default no HTTPRoute", gatewayv1.Listener{Protocol: gatewayv1.TLSProtocolType}, "HTTPRoute", false},
+		{
+			"explicit kind without group",
+			gatewayv1.Listener{Protocol: gatewayv1.HTTPProtocolType, AllowedRoutes: kinds(gatewayv1.RouteGroupKind{Kind: "GRPCRoute"})},
+			"GRPCRoute",
+			true,
+		},
+		{
+			"explicit kind not listed",
+			gatewayv1.Listener{Protocol: gatewayv1.HTTPProtocolType, AllowedRoutes: kinds(gatewayv1.RouteGroupKind{Kind: "GRPCRoute"})},
+			"HTTPRoute",
+			false,
+		},
+		{
+			"explicit kind of another group",
+			gatewayv1.Listener{
+				Protocol: gatewayv1.HTTPProtocolType,
+				AllowedRoutes: kinds(gatewayv1.RouteGroupKind{
+					Group: ptr.To(gatewayv1.Group("example.com")),
+					Kind:  "HTTPRoute",
+				}),
+			},
+			"HTTPRoute",
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listenerAllowsKind(tt.listener, tt.kind); got != tt.want {
+				t.Errorf("listenerAllowsKind() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
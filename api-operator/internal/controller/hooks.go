@@ -0,0 +1,276 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	hookPreRollout  string = "preRollout"
+	hookPostRollout string = "postRollout"
+
+	hookPhaseWaiting   string = "Waiting"
+	hookPhaseRunning   string = "Running"
+	hookPhaseSucceeded string = "Succeeded"
+	hookPhaseFailed    string = "Failed"
+
+	// hookLabel marks hook Jobs with the hook they run
+	hookLabel string = "apps.api.test/hook"
+)
+
+// preRolloutDone runs the preRollout hook of the spec version while its Deployment does not
+// exist yet and reports whether the Deployment may be created
+func (r *SimpleapiReconciler) preRolloutDone(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) (bool, error) {
+	hooks := SimpleAPIApp.Spec.Hooks
+	if hooks == nil || hooks.PreRollout == nil {
+		return true, nil
+	}
+	version := SimpleAPIApp.Spec.Version
+
+	deployment := &appsv1.Deployment{}
+	err := r.Get(ctx, client.ObjectKey{
+		Namespace: SimpleAPIApp.Namespace,
+		Name:      deploymentName(version, SimpleAPIApp.Name),
+	}, deployment)
+	if err == nil {
+		// the version is already rolled out, the hook is not run again
+		return true, nil
+	} else if !errors.IsNotFound(err) {
+		return false, err
+	}
+
+	hookStatus, err := r.runHook(ctx, SimpleAPIApp, hookPreRollout, hooks.PreRollout, version)
+	if err != nil {
+		return false, err
+	}
+	setHookStatus(SimpleAPIApp, hookStatus)
+	return hookStatus.Phase == hookPhaseSucceeded, nil
+}
+
+// postRolloutDone runs the postRollout hook of the spec version once its Deployment is
+// available and reports whether the version may be routed
+func (r *SimpleapiReconciler) postRolloutDone(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+	deployments []appsv1.Deployment,
+) (bool, error) {
+	hooks := SimpleAPIApp.Spec.Hooks
+	if hooks == nil || hooks.PostRollout == nil {
+		return true, nil
+	}
+	version := SimpleAPIApp.Spec.Version
+
+	job := &batchv1.Job{}
+	err := r.Get(ctx, client.ObjectKey{
+		Namespace: SimpleAPIApp.Namespace,
+		Name:      hookJobName(SimpleAPIApp, version, hookPostRollout),
+	}, job)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	if errors.IsNotFound(err) {
+		if !specVersionAvailable(SimpleAPIApp, deployments) {
+			setHookStatus(SimpleAPIApp, appsv1alpha1.HookStatus{
+				Hook:    hookPostRollout,
+				Version: version,
+				Phase:   hookPhaseWaiting,
+			})
+			return false, nil
+		}
+	}
+
+	hookStatus, err := r.runHook(ctx, SimpleAPIApp, hookPostRollout, hooks.PostRollout, version)
+	if err != nil {
+		return false, err
+	}
+	setHookStatus(SimpleAPIApp, hookStatus)
+	return hookStatus.Phase == hookPhaseSucceeded, nil
+}
+
+// runHook creates the hook Job of the version when it does not exist and returns its outcome
+func (r *SimpleapiReconciler) runHook(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+	hook string,
+	template *batchv1.JobTemplateSpec,
+	version string,
+) (appsv1alpha1.HookStatus, error) {
+	logger := log.FromContext(ctx)
+	name := hookJobName(SimpleAPIApp, version, hook)
+	hookStatus := appsv1alpha1.HookStatus{
+		Hook:    hook,
+		Version: version,
+		Job:     name,
+		Phase:   hookPhaseRunning,
+		Logs:    fmt.Sprintf("kubectl logs -n %s job/%s", SimpleAPIApp.Namespace, name),
+	}
+
+	job := &batchv1.Job{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: SimpleAPIApp.Namespace, Name: name}, job)
+	if errors.IsNotFound(err) {
+		job = r.constructHookJob(SimpleAPIApp, hook, template, version)
+		if err := controllerutil.SetControllerReference(SimpleAPIApp, job, r.Scheme); err != nil {
+			return hookStatus, err
+		}
+		if err := r.Create(ctx, job); err != nil {
+			return hookStatus, err
+		}
+		logger.Info("Started hook job", "hook", hook, "Job", name)
+		return hookStatus, nil
+	} else if err != nil {
+		return hookStatus, err
+	}
+
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			hookStatus.Phase = hookPhaseSucceeded
+		case batchv1.JobFailed:
+			hookStatus.Phase = hookPhaseFailed
+		}
+	}
+	return hookStatus, nil
+}
+
+// constructHookJob copies the hook template and tells the containers which version and
+// Service they run against. The app and version labels are left off the pods so the
+// version Service never selects them.
+func (r *SimpleapiReconciler) constructHookJob(
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+	hook string,
+	template *batchv1.JobTemplateSpec,
+	version string,
+) *batchv1.Job {
+	labels := map[string]string{}
+	for k, v := range template.Labels {
+		labels[k] = v
+	}
+	labels[simpleapiLabel] = SimpleAPIApp.Name
+	labels[hookLabel] = hook
+	labels["version"] = version
+
+	spec := *template.Spec.DeepCopy()
+	// the hook pods are labelled too, the NetworkPolicy admits them to the version Service
+	if spec.Template.Labels == nil {
+		spec.Template.Labels = map[string]string{}
+	}
+	spec.Template.Labels[simpleapiLabel] = SimpleAPIApp.Name
+	spec.Template.Labels[hookLabel] = hook
+	if spec.Template.Spec.RestartPolicy == "" {
+		spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+	}
+	env := []corev1.EnvVar{
+		{Name: "SIMPLEAPI_VERSION", Value: version},
+		{
+			Name: "SIMPLEAPI_SERVICE_URL",
+			Value: fmt.Sprintf(
+				"http://%s.%s.svc:%d",
+				serviceName(version, SimpleAPIApp.Name),
+				SimpleAPIApp.Namespace,
+				servicePortNumber(routedPort(SimpleAPIApp)),
+			),
+		},
+	}
+	for i := range spec.Template.Spec.Containers {
+		spec.Template.Spec.Containers[i].Env = append(spec.Template.Spec.Containers[i].Env, env...)
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        hookJobName(SimpleAPIApp, version, hook),
+			Namespace:   SimpleAPIApp.Namespace,
+			Labels:      labels,
+			Annotations: template.Annotations,
+		},
+		Spec: spec,
+	}
+}
+
+// setHookStatus records the hook outcome and folds all hooks into the HooksSucceeded condition
+func setHookStatus(SimpleAPIApp *appsv1alpha1.Simpleapi, hookStatus appsv1alpha1.HookStatus) {
+	hooks := []appsv1alpha1.HookStatus{}
+	for _, h := range SimpleAPIApp.Status.Hooks {
+		if h.Hook != hookStatus.Hook && h.Version == hookStatus.Version {
+			hooks = append(hooks, h)
+		}
+	}
+	SimpleAPIApp.Status.Hooks = append(hooks, hookStatus)
+
+	cond := metav1.Condition{
+		Type:               appsv1alpha1.ConditionHooksSucceeded,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Succeeded",
+		Message:            "all hooks of version " + hookStatus.Version + " succeeded",
+		ObservedGeneration: SimpleAPIApp.Generation,
+	}
+	for _, h := range SimpleAPIApp.Status.Hooks {
+		switch h.Phase {
+		case hookPhaseFailed:
+			cond.Status = metav1.ConditionFalse
+			cond.Reason = "HookFailed"
+			cond.Message = fmt.Sprintf("%s hook failed, see %s", h.Hook, h.Logs)
+			meta.SetStatusCondition(&SimpleAPIApp.Status.Conditions, cond)
+			return
+		case hookPhaseWaiting, hookPhaseRunning:
+			cond.Status = metav1.ConditionUnknown
+			cond.Reason = "HookRunning"
+			cond.Message = fmt.Sprintf("%s hook is %s", h.Hook, strings.ToLower(h.Phase))
+		}
+	}
+	meta.SetStatusCondition(&SimpleAPIApp.Status.Conditions, cond)
+}
+
+// cleanupHookJobs removes the hook Jobs of versions that are not retained
+func (r *SimpleapiReconciler) cleanupHookJobs(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+	retained []string,
+) error {
+	var jobList batchv1.JobList
+	if err := r.List(
+		ctx,
+		&jobList,
+		client.InNamespace(SimpleAPIApp.Namespace),
+		client.MatchingLabels{simpleapiLabel: SimpleAPIApp.Name},
+		client.HasLabels{hookLabel},
+	); err != nil {
+		return err
+	}
+	keep := map[string]bool{}
+	for _, ver := range retained {
+		keep[ver] = true
+	}
+	for i := range jobList.Items {
+		job := &jobList.Items[i]
+		if keep[job.Labels["version"]] || !metav1.IsControlledBy(job, SimpleAPIApp) {
+			continue
+		}
+		if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil &&
+			!errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func hookJobName(SimpleAPIApp *appsv1alpha1.Simpleapi, version string, hook string) string {
+	return dnsSafeName(fmt.Sprintf("%s-%s-%s", SimpleAPIApp.Name, version, hook), maxNameLength)
+}
@@ -0,0 +1,256 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// hookTemplate returns a Job template running a single container
+func hookTemplate() *batchv1.JobTemplateSpec {
+	return &batchv1.JobTemplateSpec{
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "hook", Image: "hook:1"}}},
+			},
+		},
+	}
+}
+
+// hookJob returns the hook Job of version v2 with the given finished condition, none while running
+func hookJob(app *appsv1alpha1.Simpleapi, hook string, finished batchv1.JobConditionType) *batchv1.Job {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            hookJobName(app, "v2", hook),
+			Namespace:       app.Namespace,
+			OwnerReferences: controlledBy(app),
+		},
+	}
+	if finished != "" {
+		job.Status.Conditions = []batchv1.JobCondition{{Type: finished, Status: corev1.ConditionTrue}}
+	}
+	return job
+}
+
+func TestPreRolloutDone(t *testing.T) {
+	app := testSimpleapi()
+	app.Spec.Version = "v2"
+	app.Spec.Hooks = &appsv1alpha1.HooksSpec{PreRollout: hookTemplate()}
+	tests := []struct {
+		name          string
+		hooks         *appsv1alpha1.HooksSpec
+		existing      []client.Object
+		wantDone      bool
+		wantPhase     string
+		wantJob       bool
+		wantCondition metav1.ConditionStatus
+	}{
+		{name: "no hook", hooks: &appsv1alpha1.HooksSpec{}, wantDone: true},
+		{
+			name:  "version already rolled out",
+			hooks: app.Spec.Hooks,
+			existing: []client.Object{&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: deploymentName("v2", app.Name), Namespace: app.Namespace},
+			}},
+			wantDone: true,
+		},
+		{
+			name:          "job started",
+			hooks:         app.Spec.Hooks,
+			wantPhase:     hookPhaseRunning,
+			wantJob:       true,
+			wantCondition: metav1.ConditionUnknown,
+		},
+		{
+			name:          "job running",
+			hooks:         app.Spec.Hooks,
+			existing:      []client.Object{hookJob(app, hookPreRollout, "")},
+			wantPhase:     hookPhaseRunning,
+			wantJob:       true,
+			wantCondition: metav1.ConditionUnknown,
+		},
+		{
+			name:          "job succeeded",
+			hooks:         app.Spec.Hooks,
+			existing:      []client.Object{hookJob(app, hookPreRollout, batchv1.JobComplete)},
+			wantDone:      true,
+			wantPhase:     hookPhaseSucceeded,
+			wantJob:       true,
+			wantCondition: metav1.ConditionTrue,
+		},
+		{
+			name:          "job failed",
+			hooks:         app.Spec.Hooks,
+			existing:      []client.Object{hookJob(app, hookPreRollout, batchv1.JobFailed)},
+			wantPhase:     hookPhaseFailed,
+			wantJob:       true,
+			wantCondition: metav1.ConditionFalse,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := app.DeepCopy()
+			app.Spec.Hooks = tt.hooks
+			r := newTestReconciler(t, tt.existing...)
+
+			done, err := r.preRolloutDone(context.Background(), app)
+			if err != nil {
+				t.Fatalf("preRolloutDone() error = %v", err)
+			}
+			if done != tt.wantDone {
+				t.Errorf("preRolloutDone() = %v, want %v", done, tt.wantDone)
+			}
+			checkHookStatus(t, r, app, hookPreRollout, tt.wantPhase, tt.wantJob, tt.wantCondition)
+		})
+	}
+}
+
+func TestPostRolloutDone(t *testing.T) {
+	app := testSimpleapi()
+	app.Spec.Version = "v2"
+	app.Spec.Hooks = &appsv1alpha1.HooksSpec{PostRollout: hookTemplate()}
+	deployment := func(available bool) []appsv1.Deployment {
+		dep := appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"version": "v2"}},
+			Status:     appsv1.DeploymentStatus{ReadyReplicas: 1},
+		}
+		if available {
+			dep.Status.Conditions = []appsv1.DeploymentCondition{
+				{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionTrue},
+			}
+		}
+		return []appsv1.Deployment{dep}
+	}
+	tests := []struct {
+		name          string
+		existing      []client.Object
+		deployments   []appsv1.Deployment
+		wantDone      bool
+		wantPhase     string
+		wantJob       bool
+		wantCondition metav1.ConditionStatus
+	}{
+		{
+			name:          "waits for the version to be available",
+			deployments:   deployment(false),
+			wantPhase:     hookPhaseWaiting,
+			wantCondition: metav1.ConditionUnknown,
+		},
+		{
+			name:          "job started once available",
+			deployments:   deployment(true),
+			wantPhase:     hookPhaseRunning,
+			wantJob:       true,
+			wantCondition: metav1.ConditionUnknown,
+		},
+		{
+			name:          "succeeded job is kept while the version is unavailable",
+			existing:      []client.Object{hookJob(app, hookPostRollout, batchv1.JobComplete)},
+			deployments:   deployment(false),
+			wantDone:      true,
+			wantPhase:     hookPhaseSucceeded,
+			wantJob:       true,
+			wantCondition: metav1.ConditionTrue,
+		},
+		{
+			name:          "job failed",
+			existing:      []client.Object{hookJob(app, hookPostRollout, batchv1.JobFailed)},
+			deployments:   deployment(true),
+			wantPhase:     hookPhaseFailed,
+			wantJob:       true,
+			wantCondition: metav1.ConditionFalse,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := app.DeepCopy()
+			r := newTestReconciler(t, tt.existing...)
+
+			done, err := r.postRolloutDone(context.Background(), app, tt.deployments)
+			if err != nil {
+				t.Fatalf("postRolloutDone() error = %v", err)
+			}
+			if done != tt.wantDone {
+				t.Errorf("postRolloutDone() = %v, want %v", done, tt.wantDone)
+			}
+			checkHookStatus(t, r, app, hookPostRollout, tt.wantPhase, tt.wantJob, tt.wantCondition)
+		})
+	}
+}
+
+// checkHookStatus compares the hook status, the HooksSucceeded condition and the hook Job of version v2
+func checkHookStatus(
+	t *testing.T,
+	r *SimpleapiReconciler,
+	app *appsv1alpha1.Simpleapi,
+	hook string,
+	wantPhase string,
+	wantJob bool,
+	wantCondition metav1.ConditionStatus,
+) {
+	t.Helper()
+	phase := ""
+	for _, h := range app.Status.Hooks {
+		if h.Hook == hook && h.Version == "v2" {
+			phase = h.Phase
+		}
+	}
+	if phase != wantPhase {
+		t.Errorf("%s phase = %q, want %q", hook, phase, wantPhase)
+	}
+	cond := meta.FindStatusCondition(app.Status.Conditions, appsv1alpha1.ConditionHooksSucceeded)
+	if wantCondition == "" && cond != nil {
+		t.Errorf("HooksSucceeded condition = %+v, want none", cond)
+	} else if wantCondition != "" && (cond == nil || cond.Status != wantCondition) {
+		t.Errorf("HooksSucceeded condition = %+v, want %s", cond, wantCondition)
+	}
+
+	job := &batchv1.Job{}
+	err := r.Get(context.Background(), client.ObjectKey{Namespace: app.Namespace, Name: hookJobName(app, "v2", hook)}, job)
+	if apierrors.IsNotFound(err) {
+		if wantJob {
+			t.Errorf("%s Job not found", hook)
+		}
+		return
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	if !wantJob {
+		t.Errorf("%s Job created", hook)
+	}
+}
+
+func TestConstructHookJob(t *testing.T) {
+	app := testSimpleapi()
+	app.Spec.Port = 8080
+	job := newTestReconciler(t).constructHookJob(app, hookPostRollout, hookTemplate(), "v2")
+
+	if job.Labels[hookLabel] != hookPostRollout || job.Labels["version"] != "v2" {
+		t.Errorf("Job labels = %v, want the hook and version labels", job.Labels)
+	}
+	// the version Service selects on the app and version labels, the hook pods must not match it
+	podLabels := job.Spec.Template.Labels
+	if podLabels[hookLabel] != hookPostRollout || podLabels[simpleapiLabel] != app.Name ||
+		podLabels["app"] != "" || podLabels["version"] != "" {
+		t.Errorf("pod labels = %v, want the hook and simpleapi labels only", podLabels)
+	}
+	if job.Spec.Template.Spec.RestartPolicy != corev1.RestartPolicyNever {
+		t.Errorf("restartPolicy = %s, want Never", job.Spec.Template.Spec.RestartPolicy)
+	}
+	env := map[string]string{}
+	for _, e := range job.Spec.Template.Spec.Containers[0].Env {
+		env[e.Name] = e.Value
+	}
+	if env["SIMPLEAPI_VERSION"] != "v2" ||
+		env["SIMPLEAPI_SERVICE_URL"] != "http://"+serviceName("v2", app.Name)+".default.svc:8080" {
+		t.Errorf("env = %v, want the version and its Service URL", env)
+	}
+}
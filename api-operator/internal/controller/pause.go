@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"context"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// pausedAnnotation freezes the reconciliation of one Simpleapi when set to "true"
+const pausedAnnotation string = "apps.api.test/paused"
+
+func isPaused(SimpleAPIApp *appsv1alpha1.Simpleapi) bool {
+	return SimpleAPIApp.Annotations[pausedAnnotation] == "true"
+}
+
+// reconcilePaused skips every mutation of the generated objects, so they can be hand-patched,
+// and only refreshes the status. Pending spec changes are applied once the annotation is removed.
+func (r *SimpleapiReconciler) reconcilePaused(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	logger.Info("Simpleapi is paused, skipping reconciliation", "annotation", pausedAnnotation)
+
+	meta.SetStatusCondition(&SimpleAPIApp.Status.Conditions, metav1.Condition{
+		Type:               appsv1alpha1.ConditionPaused,
+		Status:             metav1.ConditionTrue,
+		Reason:             "PausedByAnnotation",
+		Message:            "reconciliation is paused by the " + pausedAnnotation + " annotation",
+		ObservedGeneration: SimpleAPIApp.Generation,
+	})
+
+	var deploymentList appsv1.DeploymentList
+	if err := r.listOwnedDeployments(ctx, SimpleAPIApp, &deploymentList); err != nil {
+		logger.Error(err, "Failed to list Deployments")
+		return ctrl.Result{}, err
+	}
+	backends := routeBackends(
+		SimpleAPIApp,
+		routedVersions(SimpleAPIApp, sortDeployments(SimpleAPIApp, deploymentList.Items)),
+	)
+
+	switch {
+	case SimpleAPIApp.Spec.IngressType == "ingress":
+		if err := r.updateIngressStatus(ctx, backends, SimpleAPIApp); err != nil {
+			logger.Error(err, "Failed to read Ingress status")
+			return ctrl.Result{}, err
+		}
+	case SimpleAPIApp.Spec.IngressType == "httproute" && r.APIs.HTTPRoute:
+		if err := r.updateHTTPRouteStatus(ctx, backends, SimpleAPIApp); err != nil {
+			logger.Error(err, "Failed to read httproute status")
+			return ctrl.Result{}, err
+		}
+	case SimpleAPIApp.Spec.IngressType == "grpcroute" && r.APIs.GRPCRoute:
+		if err := r.updateGRPCRouteStatus(ctx, backends, SimpleAPIApp); err != nil {
+			logger.Error(err, "Failed to read grpcroute status")
+			return ctrl.Result{}, err
+		}
+	case SimpleAPIApp.Spec.IngressType == "tcproute" && r.APIs.TCPRoute,
+		SimpleAPIApp.Spec.IngressType == "tlsroute" && r.APIs.TLSRoute:
+		if err := r.updateL4RouteStatus(ctx, backends, SimpleAPIApp); err != nil {
+			logger.Error(err, "Failed to read tcproute or tlsroute status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.Status().Update(ctx, SimpleAPIApp); err != nil {
+		logger.Error(err, "Failed to update Simpleapi status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestIsPaused(t *testing.T) {
+	for value, want := range map[string]bool{"true": true, "false": false, "": false, "yes": false} {
+		app := testSimpleapi()
+		if value != "" {
+			app.Annotations = map[string]string{pausedAnnotation: value}
+		}
+		if got := isPaused(app); got != want {
+			t.Errorf("isPaused() with %q = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestReconcilePausedSkipsChanges(t *testing.T) {
+	app := testSimpleapi()
+	app.Annotations = map[string]string{pausedAnnotation: "true"}
+	app.Spec.IngressType = "ingress"
+	app.Spec.Version = "v2"
+	app.Spec.Replicas = ptr.To[int32](2)
+	// the Deployment of v1 was scaled by hand while paused
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            deploymentName("v1", app.Name),
+			Namespace:       app.Namespace,
+			Labels:          map[string]string{"app": "demo", "version": "v1", simpleapiLabel: "demo"},
+			OwnerReferences: controlledBy(app),
+		},
+		Spec: appsv1.DeploymentSpec{Replicas: ptr.To[int32](5)},
+	}
+	r := newTestReconciler(t, app, deployment)
+
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result != (ctrl.Result{}) {
+		t.Errorf("Reconcile() result = %+v, want no requeue", result)
+	}
+
+	var deployments appsv1.DeploymentList
+	if err := r.List(context.Background(), &deployments); err != nil {
+		t.Fatal(err)
+	}
+	if len(deployments.Items) != 1 || *deployments.Items[0].Spec.Replicas != 5 {
+		t.Errorf("Deployments changed while paused: %d, want only v1 with 5 replicas", len(deployments.Items))
+	}
+	for _, list := range []client.ObjectList{
+		&corev1.ServiceList{},
+		&networkingv1.IngressList{},
+		&corev1.ServiceAccountList{},
+	} {
+		if err := r.List(context.Background(), list); err != nil {
+			t.Fatal(err)
+		}
+		if meta.LenList(list) != 0 {
+			t.Errorf("%T created while paused", list)
+		}
+	}
+
+	got := &appsv1alpha1.Simpleapi{}
+	if err := r.Get(context.Background(), client.ObjectKeyFromObject(app), got); err != nil {
+		t.Fatal(err)
+	}
+	if !meta.IsStatusConditionTrue(got.Status.Conditions, appsv1alpha1.ConditionPaused) {
+		t.Errorf("conditions = %+v, want Paused", got.Status.Conditions)
+	}
+}
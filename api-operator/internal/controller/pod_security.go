@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	psaapi "k8s.io/pod-security-admission/api"
+	psapolicy "k8s.io/pod-security-admission/policy"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const securityProfileRestricted string = "restricted"
+
+func restrictedProfile(SimpleAPIApp *appsv1alpha1.Simpleapi) bool {
+	return SimpleAPIApp.Spec.SecurityProfile == securityProfileRestricted
+}
+
+// containerSecurityContext returns spec.containerSecurityContext and, with the restricted
+// profile, fills the fields it leaves unset with values that pass the restricted standard
+func containerSecurityContext(SimpleAPIApp *appsv1alpha1.Simpleapi) *corev1.SecurityContext {
+	sc := SimpleAPIApp.Spec.ContainerSecurityContext.DeepCopy()
+	if !restrictedProfile(SimpleAPIApp) {
+		return sc
+	}
+	return restrictedSecurityContext(sc)
+}
+
+// restrictedSecurityContext fills the unset fields of a copy of sc with the restricted defaults
+func restrictedSecurityContext(sc *corev1.SecurityContext) *corev1.SecurityContext {
+	sc = sc.DeepCopy()
+	if sc == nil {
+		sc = &corev1.SecurityContext{}
+	}
+	if sc.AllowPrivilegeEscalation == nil {
+		sc.AllowPrivilegeEscalation = ptr.To(false)
+	}
+	if sc.RunAsNonRoot == nil {
+		sc.RunAsNonRoot = ptr.To(true)
+	}
+	if sc.ReadOnlyRootFilesystem == nil {
+		sc.ReadOnlyRootFilesystem = ptr.To(true)
+	}
+	if sc.Capabilities == nil {
+		sc.Capabilities = &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}}
+	}
+	if sc.SeccompProfile == nil {
+		sc.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+	}
+	return sc
+}
+
+// podSecurityContext returns spec.podSecurityContext and, with the restricted profile,
+// defaults runAsNonRoot and the RuntimeDefault seccomp profile for the whole pod
+func podSecurityContext(SimpleAPIApp *appsv1alpha1.Simpleapi) *corev1.PodSecurityContext {
+	sc := SimpleAPIApp.Spec.PodSecurityContext.DeepCopy()
+	if !restrictedProfile(SimpleAPIApp) {
+		return sc
+	}
+	if sc == nil {
+		sc = &corev1.PodSecurityContext{}
+	}
+	if sc.RunAsNonRoot == nil {
+		sc.RunAsNonRoot = ptr.To(true)
+	}
+	if sc.SeccompProfile == nil {
+		sc.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+	}
+	return sc
+}
+
+// checkPodSecurity verifies, with the restricted profile, that the generated pod template is
+// admitted by the pod-security.kubernetes.io/enforce level and version of the namespace
+func (r *SimpleapiReconciler) checkPodSecurity(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) (metav1.Condition, error) {
+	cond := metav1.Condition{
+		Type:               appsv1alpha1.ConditionPodSecurityAdmitted,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Admitted",
+		ObservedGeneration: SimpleAPIApp.Generation,
+	}
+	namespace := &corev1.Namespace{}
+	if err := r.Get(ctx, client.ObjectKey{Name: SimpleAPIApp.Namespace}, namespace); err != nil {
+		return cond, err
+	}
+	policy, errs := psaapi.PolicyToEvaluate(namespace.Labels, psaapi.Policy{
+		Enforce: psaapi.LevelVersion{Level: psaapi.LevelPrivileged, Version: psaapi.LatestVersion()},
+	})
+	if len(errs) > 0 {
+		// the admission plugin rejects every pod of a namespace with invalid labels
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "InvalidPolicy"
+		cond.Message = fmt.Sprintf(
+			"namespace %s has invalid pod security labels: %v",
+			SimpleAPIApp.Namespace,
+			errs.ToAggregate(),
+		)
+		return cond, nil
+	}
+
+	deployment, err := r.constructDeployment(*SimpleAPIApp, 0)
+	if err != nil {
+		// reported with the PodTemplateValid condition
+		return cond, nil
+	}
+	violations, err := podSecurityViolations(policy.Enforce, &deployment.Spec.Template)
+	if err != nil {
+		return cond, err
+	}
+	if len(violations) > 0 {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "Violation"
+		cond.Message = fmt.Sprintf(
+			"the pods would violate the %s level enforced on namespace %s: %s",
+			policy.Enforce.String(),
+			SimpleAPIApp.Namespace,
+			strings.Join(violations, "; "),
+		)
+		return cond, nil
+	}
+	cond.Message = fmt.Sprintf(
+		"the pods meet the %s level enforced on namespace %s",
+		policy.Enforce.String(),
+		SimpleAPIApp.Namespace,
+	)
+	return cond, nil
+}
+
+// podSecurityViolations evaluates the pod template with the checks of the Pod Security
+// Admission plugin, one violation per failed check
+func podSecurityViolations(enforce psaapi.LevelVersion, template *corev1.PodTemplateSpec) ([]string, error) {
+	evaluator, err := psapolicy.NewEvaluator(psapolicy.DefaultChecks())
+	if err != nil {
+		return nil, err
+	}
+	violations := []string{}
+	for _, result := range evaluator.EvaluatePod(enforce, &template.ObjectMeta, &template.Spec) {
+		if result.Allowed {
+			continue
+		}
+		if result.ForbiddenDetail == "" {
+			violations = append(violations, result.ForbiddenReason)
+			continue
+		}
+		violations = append(violations, fmt.Sprintf("%s (%s)", result.ForbiddenReason, result.ForbiddenDetail))
+	}
+	return violations, nil
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	psaapi "k8s.io/pod-security-admission/api"
+	"k8s.io/utils/ptr"
+)
+
+func TestPodSecurityViolations(t *testing.T) {
+	restrictedSC := func() *corev1.SecurityContext {
+		return &corev1.SecurityContext{
+			AllowPrivilegeEscalation: ptr.To(false),
+			RunAsNonRoot:             ptr.To(true),
+			SeccompProfile:           &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+		}
+	}
+	pod := func(sc *corev1.SecurityContext) *corev1.PodSpec {
+		return &corev1.PodSpec{Containers: []corev1.Container{{Name: "api", SecurityContext: sc}}}
+	}
+	baseline := psaapi.LevelVersion{Level: psaapi.LevelBaseline, Version: psaapi.LatestVersion()}
+	restricted := psaapi.LevelVersion{Level: psaapi.LevelRestricted, Version: psaapi.LatestVersion()}
+	tests := []struct {
+		name  string
+		level psaapi.LevelVersion
+		pod   *corev1.PodSpec
+		want  []string
+	}{
+		{
+			"privileged level is not checked",
+			psaapi.LevelVersion{Level: psaapi.LevelPrivileged, Version: psaapi.LatestVersion()},
+			pod(&corev1.SecurityContext{Privileged: ptr.To(true)}),
+			nil,
+		},
+		{"baseline plain container", baseline, pod(nil), nil},
+		{
+			"baseline privileged container",
+			baseline,
+			pod(&corev1.SecurityContext{Privileged: ptr.To(true)}),
+			[]string{`privileged (container "api" must not set securityContext.privileged=true)`},
+		},
+		{
+			"baseline host network",
+			baseline,
+			&corev1.PodSpec{HostNetwork: true, Containers: []corev1.Container{{Name: "api"}}},
+			[]string{`host namespaces (hostNetwork=true)`},
+		},
+		{
+			"baseline capability outside the list",
+			baseline,
+			pod(&corev1.SecurityContext{Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"SYS_ADMIN", "CHOWN"}}}),
+			[]string{`non-default capabilities (container "api" must not include "SYS_ADMIN" in securityContext.capabilities.add)`},
+		},
+		{
+			"baseline unconfined seccomp on an init container",
+			baseline,
+			&corev1.PodSpec{
+				InitContainers: []corev1.Container{{
+					Name:            "init",
+					SecurityContext: &corev1.SecurityContext{SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeUnconfined}},
+				}},
+				Containers: []corev1.Container{{Name: "api"}},
+			},
+			[]string{`seccompProfile (container "init" must not set securityContext.seccompProfile.type to "Unconfined")`},
+		},
+		{"restricted compliant container", restricted, pod(restrictedSC()), nil},
+		{
+			"restricted settings inherited from the pod",
+			restricted,
+			&corev1.PodSpec{
+				SecurityContext: &corev1.PodSecurityContext{
+					RunAsNonRoot:   ptr.To(true),
+					SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+				},
+				Containers: []corev1.Container{{
+					Name: "api",
+					SecurityContext: &corev1.SecurityContext{
+						AllowPrivilegeEscalation: ptr.To(false),
+						Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+					},
+				}},
+			},
+			nil,
+		},
+		{
+			"restricted plain container",
+			restricted,
+			pod(nil),
+			[]string{
+				`allowPrivilegeEscalation != false (container "api" must set securityContext.allowPrivilegeEscalation=false)`,
+				`unrestricted capabilities (container "api" must set securityContext.capabilities.drop=["ALL"])`,
+				`runAsNonRoot != true (pod or container "api" must set securityContext.runAsNonRoot=true)`,
+				`seccompProfile (pod or container "api" must set securityContext.seccompProfile.type to "RuntimeDefault" or "Localhost")`,
+			},
+		},
+		{
+			"restricted root user",
+			restricted,
+			func() *corev1.PodSpec {
+				sc := restrictedSC()
+				sc.RunAsUser = ptr.To[int64](0)
+				return pod(sc)
+			}(),
+			[]string{`runAsUser=0 (container "api" must not set runAsUser=0)`},
+		},
+		{
+			"restricted only allows adding NET_BIND_SERVICE",
+			restricted,
+			func() *corev1.PodSpec {
+				sc := restrictedSC()
+				sc.Capabilities.Add = []corev1.Capability{"NET_BIND_SERVICE", "CHOWN"}
+				return pod(sc)
+			}(),
+			[]string{`unrestricted capabilities (container "api" must not include "CHOWN" in securityContext.capabilities.add)`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := podSecurityViolations(tt.level, &corev1.PodTemplateSpec{Spec: *tt.pod})
+			if err != nil {
+				t.Fatalf("podSecurityViolations() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("podSecurityViolations() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+)
+
+// serviceAppProtocols maps the appProtocol of spec.ports to the Service appProtocol
+var serviceAppProtocols = map[string]string{
+	"http": "http",
+	"h2c":  "kubernetes.io/h2c",
+	"grpc": "grpc",
+}
+
+// versionPorts returns spec.ports or, when it is empty, the single http port of spec.port
+func versionPorts(SimpleAPIApp *appsv1alpha1.Simpleapi) []appsv1alpha1.PortSpec {
+	if len(SimpleAPIApp.Spec.Ports) > 0 {
+		return SimpleAPIApp.Spec.Ports
+	}
+	return []appsv1alpha1.PortSpec{
+		{
+			Name:          servicePortName,
+			ContainerPort: SimpleAPIApp.Spec.Port,
+			Protocol:      corev1.ProtocolTCP,
+		},
+	}
+}
+
+// routedPort returns the port the routes target, with ingressType grpcroute the first grpc or
+// h2c port, otherwise the port named http or else the first port with an application protocol,
+// the other ports are only exposed on the Service
+func routedPort(SimpleAPIApp *appsv1alpha1.Simpleapi) appsv1alpha1.PortSpec {
+	ports := versionPorts(SimpleAPIApp)
+	if SimpleAPIApp.Spec.IngressType == "grpcroute" {
+		for _, port := range ports {
+			if port.AppProtocol == "grpc" || port.AppProtocol == "h2c" {
+				return port
+			}
+		}
+	}
+	for _, port := range ports {
+		if port.Name == servicePortName {
+			return port
+		}
+	}
+	for _, port := range ports {
+		if port.AppProtocol != "" {
+			return port
+		}
+	}
+	return ports[0]
+}
+
+func servicePortNumber(port appsv1alpha1.PortSpec) int32 {
+	return ptr.Deref(port.ServicePort, port.ContainerPort)
+}
+
+// portProtocol returns the declared protocol of the port, TCP when it is not set
+func portProtocol(port appsv1alpha1.PortSpec) corev1.Protocol {
+	if port.Protocol == "" {
+		return corev1.ProtocolTCP
+	}
+	return port.Protocol
+}
+
+func containerPorts(SimpleAPIApp *appsv1alpha1.Simpleapi) []corev1.ContainerPort {
+	if len(SimpleAPIApp.Spec.Ports) == 0 {
+		return []corev1.ContainerPort{
+			{ContainerPort: SimpleAPIApp.Spec.Port},
+		}
+	}
+	ports := make([]corev1.ContainerPort, len(SimpleAPIApp.Spec.Ports))
+	for i, port := range SimpleAPIApp.Spec.Ports {
+		ports[i] = corev1.ContainerPort{
+			Name:          port.Name,
+			ContainerPort: port.ContainerPort,
+			Protocol:      port.Protocol,
+		}
+	}
+	return ports
+}
+
+func servicePorts(SimpleAPIApp *appsv1alpha1.Simpleapi) []corev1.ServicePort {
+	if len(SimpleAPIApp.Spec.Ports) == 0 {
+		return []corev1.ServicePort{
+			{
+				Name:       servicePortName,
+				Port:       SimpleAPIApp.Spec.Port,
+				TargetPort: intstr.FromInt(int(SimpleAPIApp.Spec.Port)),
+				Protocol:   corev1.ProtocolTCP,
+			},
+		}
+	}
+	ports := make([]corev1.ServicePort, len(SimpleAPIApp.Spec.Ports))
+	for i, port := range SimpleAPIApp.Spec.Ports {
+		ports[i] = corev1.ServicePort{
+			Name:       port.Name,
+			Port:       servicePortNumber(port),
+			TargetPort: intstr.FromString(port.Name),
+			Protocol:   portProtocol(port),
+		}
+		if appProtocol, ok := serviceAppProtocols[port.AppProtocol]; ok {
+			ports[i].AppProtocol = ptr.To(appProtocol)
+		}
+	}
+	return ports
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"testing"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+)
+
+func TestServicePorts(t *testing.T) {
+	tests := []struct {
+		name          string
+		port          int32
+		ports         []appsv1alpha1.PortSpec
+		wantService   []corev1.ServicePort
+		wantContainer []corev1.ContainerPort
+	}{
+		{
+			name: "single port",
+			port: 8080,
+			wantService: []corev1.ServicePort{
+				{Name: "http", Port: 8080, TargetPort: intstr.FromInt32(8080), Protocol: corev1.ProtocolTCP},
+			},
+			wantContainer: []corev1.ContainerPort{{ContainerPort: 8080}},
+		},
+		{
+			name: "named ports",
+			port: 8080,
+			ports: []appsv1alpha1.PortSpec{
+				{Name: "http", ContainerPort: 8080, ServicePort: ptr.To[int32](80), AppProtocol: "http"},
+				{Name: "grpc", ContainerPort: 9000, AppProtocol: "h2c"},
+				{Name: "metrics", ContainerPort: 9100, Protocol: corev1.ProtocolTCP},
+				{Name: "stats", ContainerPort: 8125, Protocol: corev1.ProtocolUDP},
+			},
+			wantService: []corev1.ServicePort{
+				{
+					Name:        "http",
+					Port:        80,
+					TargetPort:  intstr.FromString("http"),
+					Protocol:    corev1.ProtocolTCP,
+					AppProtocol: ptr.To("http"),
+				},
+				{
+					Name:        "grpc",
+					Port:        9000,
+					TargetPort:  intstr.FromString("grpc"),
+					Protocol:    corev1.ProtocolTCP,
+					AppProtocol: ptr.To("kubernetes.io/h2c"),
+				},
+				{Name: "metrics", Port: 9100, TargetPort: intstr.FromString("metrics"), Protocol: corev1.ProtocolTCP},
+				{Name: "stats", Port: 8125, TargetPort: intstr.FromString("stats"), Protocol: corev1.ProtocolUDP},
+			},
+			wantContainer: []corev1.ContainerPort{
+				{Name: "http", ContainerPort: 8080},
+				{Name: "grpc", ContainerPort: 9000},
+				{Name: "metrics", ContainerPort: 9100, Protocol: corev1.ProtocolTCP},
+				{Name: "stats", ContainerPort: 8125, Protocol: corev1.ProtocolUDP},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testSimpleapi()
+			app.Spec.Port = tt.port
+			app.Spec.Ports = tt.ports
+			if got := servicePorts(app); !equality.Semantic.DeepEqual(got, tt.wantService) {
+				t.Errorf("servicePorts() = %+v, want %+v", got, tt.wantService)
+			}
+			if got := containerPorts(app); !equality.Semantic.DeepEqual(got, tt.wantContainer) {
+				t.Errorf("containerPorts() = %+v, want %+v", got, tt.wantContainer)
+			}
+		})
+	}
+}
+
+func TestRoutedPort(t *testing.T) {
+	tests := []struct {
+		name        string
+		ingressType string
+		ports       []appsv1alpha1.PortSpec
+		want        string
+		// wantIngressPort is the Service port the Ingress backend targets
+		wantIngressPort int32
+	}{
+		{
+			name:            "single port",
+			ingressType:     "ingress",
+			want:            "http",
+			wantIngressPort: 8080,
+		},
+		{
+			name:        "port named http",
+			ingressType: "ingress",
+			ports: []appsv1alpha1.PortSpec{
+				{Name: "metrics", ContainerPort: 9100, AppProtocol: "http"},
+				{Name: "http", ContainerPort: 8080, ServicePort: ptr.To[int32](80)},
+			},
+			want:            "http",
+			wantIngressPort: 80,
+		},
+		{
+			name:        "first port with an application protocol",
+			ingressType: "ingress",
+			ports: []appsv1alpha1.PortSpec{
+				{Name: "metrics", ContainerPort: 9100},
+				{Name: "web", ContainerPort: 8080, ServicePort: ptr.To[int32](81), AppProtocol: "http"},
+			},
+			want:            "web",
+			wantIngressPort: 81,
+		},
+		{
+			name:        "grpc port of a grpcroute",
+			ingressType: "grpcroute",
+			ports: []appsv1alpha1.PortSpec{
+				{Name: "http", ContainerPort: 8080},
+				{Name: "grpc", ContainerPort: 9000, AppProtocol: "grpc"},
+			},
+			want: "grpc",
+		},
+		{
+			name:        "first port otherwise",
+			ingressType: "ingress",
+			ports: []appsv1alpha1.PortSpec{
+				{Name: "admin", ContainerPort: 9000},
+				{Name: "metrics", ContainerPort: 9100},
+			},
+			want:            "admin",
+			wantIngressPort: 9000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testSimpleapi()
+			app.Spec.Port = 8080
+			app.Spec.Ports = tt.ports
+			app.Spec.IngressType = tt.ingressType
+			if got := routedPort(app); got.Name != tt.want {
+				t.Errorf("routedPort() = %s, want %s", got.Name, tt.want)
+			}
+			if tt.wantIngressPort == 0 {
+				return
+			}
+			ingress := newTestReconciler(t).constructIngress(
+				[]routeBackend{{Version: "v1", Path: "/api/v1"}}, app.Namespace, app)
+			port := ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Port
+			if port.Number != tt.wantIngressPort || port.Name != "" {
+				t.Errorf("Ingress backend port = %+v, want number %d", port, tt.wantIngressPort)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+)
+
+// maxPrunesPerReconcile caps how many versions one reconcile deletes, the rest follow on
+// the next passes
+const maxPrunesPerReconcile = 2
+
+// liveRouteServices returns the Services the generated Ingress or Gateway API routes send traffic to,
+// as read from the cluster, a version behind one of them is never pruned
+func (r *SimpleapiReconciler) liveRouteServices(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) (map[string]bool, error) {
+	services := map[string]bool{}
+
+	// the main Ingress and the Ingresses of deprecated versions
+	var ingressList networkingv1.IngressList
+	if err := r.List(ctx, &ingressList, client.InNamespace(SimpleAPIApp.Namespace)); err != nil {
+		return nil, err
+	}
+	for i := range ingressList.Items {
+		if !metav1.IsControlledBy(&ingressList.Items[i], SimpleAPIApp) {
+			continue
+		}
+		for _, rule := range ingressList.Items[i].Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				if path.Backend.Service != nil {
+					services[path.Backend.Service.Name] = true
+				}
+			}
+		}
+	}
+
+	if r.APIs.GRPCRoute {
+		grpcroute := &gatewayv1.GRPCRoute{}
+		err := r.Get(ctx, client.ObjectKey{Namespace: SimpleAPIApp.Namespace, Name: getGRPCRouteName(SimpleAPIApp)}, grpcroute)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		for _, rule := range grpcroute.Spec.Rules {
+			for _, ref := range rule.BackendRefs {
+				services[string(ref.Name)] = true
+			}
+		}
+	}
+
+	if r.APIs.TCPRoute {
+		tcproute := &gatewayv1alpha2.TCPRoute{}
+		err := r.Get(ctx, client.ObjectKey{Namespace: SimpleAPIApp.Namespace, Name: getTCPRouteName(SimpleAPIApp)}, tcproute)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		for _, rule := range tcproute.Spec.Rules {
+			for _, ref := range rule.BackendRefs {
+				services[string(ref.Name)] = true
+			}
+		}
+	}
+	if r.APIs.TLSRoute {
+		tlsroute := &gatewayv1alpha2.TLSRoute{}
+		err := r.Get(ctx, client.ObjectKey{Namespace: SimpleAPIApp.Namespace, Name: getTLSRouteName(SimpleAPIApp)}, tlsroute)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		for _, rule := range tlsroute.Spec.Rules {
+			for _, ref := range rule.BackendRefs {
+				services[string(ref.Name)] = true
+			}
+		}
+	}
+
+	if !r.APIs.HTTPRoute {
+		return services, nil
+	}
+	for _, name := range []string{getHTTPRouteName(SimpleAPIApp), getPreviewHTTPRouteName(SimpleAPIApp)} {
+		httproute := &gatewayv1.HTTPRoute{}
+		err := r.Get(ctx, client.ObjectKey{Namespace: SimpleAPIApp.Namespace, Name: name}, httproute)
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, rule := range httproute.Spec.Rules {
+			for _, ref := range rule.BackendRefs {
+				services[string(ref.Name)] = true
+			}
+			for _, filter := range rule.Filters {
+				if filter.RequestMirror != nil {
+					services[string(filter.RequestMirror.BackendRef.Name)] = true
+				}
+			}
+		}
+	}
+	return services, nil
+}
+
+// pruneCondition folds the versions that could not be pruned into the PruneSucceeded condition
+func pruneCondition(SimpleAPIApp *appsv1alpha1.Simpleapi, failures []string) metav1.Condition {
+	if len(failures) > 0 {
+		return metav1.Condition{
+			Type:               appsv1alpha1.ConditionPruneSucceeded,
+			Status:             metav1.ConditionFalse,
+			Reason:             "PruneFailed",
+			Message:            fmt.Sprintf("old versions were not pruned: %s", strings.Join(failures, "; ")),
+			ObservedGeneration: SimpleAPIApp.Generation,
+		}
+	}
+	return metav1.Condition{
+		Type:               appsv1alpha1.ConditionPruneSucceeded,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Pruned",
+		Message:            "all old versions are pruned or draining",
+		ObservedGeneration: SimpleAPIApp.Generation,
+	}
+}
@@ -0,0 +1,267 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestCleanupOldDeployments(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	app := &appsv1alpha1.Simpleapi{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default", UID: "app-uid"},
+	}
+	owner := *metav1.NewControllerRef(app, appsv1alpha1.GroupVersion.WithKind("Simpleapi"))
+	retireAt := func(d time.Duration) string {
+		return now.Add(d).UTC().Format(time.RFC3339)
+	}
+
+	type version struct {
+		name     string
+		retireAt string
+		// depNotOwned and svcNotOwned drop the controller reference of the Deployment or its Service
+		depNotOwned bool
+		svcNotOwned bool
+		// staleUID makes the listed Deployment differ from the one in the cluster
+		staleUID bool
+	}
+	tests := []struct {
+		name        string
+		versions    []version
+		retained    []string
+		routed      []string
+		wantPresent []string
+		wantRetire  map[string]string
+		wantRequeue time.Duration
+		wantStatus  metav1.ConditionStatus
+	}{
+		{
+			name:        "old version starts draining",
+			versions:    []version{{name: "v1"}, {name: "v2"}},
+			retained:    []string{"v2"},
+			wantPresent: []string{"v1", "v2"},
+			wantRetire:  map[string]string{"v1": retireAt(defaultDrainSeconds * time.Second)},
+			wantRequeue: defaultDrainSeconds * time.Second,
+			wantStatus:  metav1.ConditionTrue,
+		},
+		{
+			name:        "old version still draining",
+			versions:    []version{{name: "v1", retireAt: retireAt(20 * time.Second)}, {name: "v2"}},
+			retained:    []string{"v2"},
+			wantPresent: []string{"v1", "v2"},
+			wantRetire:  map[string]string{"v1": retireAt(20 * time.Second)},
+			wantRequeue: 20 * time.Second,
+			wantStatus:  metav1.ConditionTrue,
+		},
+		{
+			name:        "drained version deleted",
+			versions:    []version{{name: "v1", retireAt: retireAt(-time.Second)}, {name: "v2"}},
+			retained:    []string{"v2"},
+			wantPresent: []string{"v2"},
+			wantStatus:  metav1.ConditionTrue,
+		},
+		{
+			name:        "retained version stops draining",
+			versions:    []version{{name: "v1", retireAt: retireAt(-time.Second)}, {name: "v2"}},
+			retained:    []string{"v1", "v2"},
+			wantPresent: []string{"v1", "v2"},
+			wantStatus:  metav1.ConditionTrue,
+		},
+		{
+			name:        "version still routed by the Ingress",
+			versions:    []version{{name: "v1", retireAt: retireAt(-time.Second)}, {name: "v2"}},
+			retained:    []string{"v2"},
+			routed:      []string{"v1", "v2"},
+			wantPresent: []string{"v1", "v2"},
+			wantRetire:  map[string]string{"v1": retireAt(-time.Second)},
+			wantRequeue: pruneRequeue,
+			wantStatus:  metav1.ConditionFalse,
+		},
+		{
+			name: "at most two versions deleted per reconcile",
+			versions: []version{
+				{name: "v1", retireAt: retireAt(-time.Second)},
+				{name: "v2", retireAt: retireAt(-time.Second)},
+				{name: "v3", retireAt: retireAt(-time.Second)},
+				{name: "v4"},
+			},
+			retained:    []string{"v4"},
+			wantPresent: []string{"v3", "v4"},
+			wantRetire:  map[string]string{"v3": retireAt(-time.Second)},
+			wantRequeue: pruneRequeue,
+			wantStatus:  metav1.ConditionTrue,
+		},
+		{
+			name:        "deployment not owned is left alone",
+			versions:    []version{{name: "v1", depNotOwned: true}, {name: "v2"}},
+			retained:    []string{"v2"},
+			wantPresent: []string{"v1", "v2"},
+			wantStatus:  metav1.ConditionFalse,
+		},
+		{
+			name:        "service not owned is left alone",
+			versions:    []version{{name: "v1", retireAt: retireAt(-time.Second), svcNotOwned: true}, {name: "v2"}},
+			retained:    []string{"v2"},
+			wantPresent: []string{"v1", "v2"},
+			wantRetire:  map[string]string{"v1": retireAt(-time.Second)},
+			wantStatus:  metav1.ConditionFalse,
+		},
+		{
+			name:        "recreated deployment is not deleted",
+			versions:    []version{{name: "v1", retireAt: retireAt(-time.Second), staleUID: true}, {name: "v2"}},
+			retained:    []string{"v2"},
+			wantPresent: []string{"v1", "v2"},
+			wantRetire:  map[string]string{"v1": retireAt(-time.Second)},
+			wantStatus:  metav1.ConditionFalse,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := []client.Object{}
+			listed := []appsv1.Deployment{}
+			for _, v := range tt.versions {
+				dep := &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            deploymentName(v.name, app.Name),
+						Namespace:       app.Namespace,
+						UID:             types.UID("dep-" + v.name),
+						Labels:          map[string]string{"app": app.Name, "version": v.name},
+						OwnerReferences: []metav1.OwnerReference{owner},
+					},
+				}
+				if v.retireAt != "" {
+					dep.Annotations = map[string]string{retireAtAnnotation: v.retireAt}
+				}
+				if v.depNotOwned {
+					dep.OwnerReferences = nil
+				}
+				svc := &corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            serviceName(v.name, app.Name),
+						Namespace:       app.Namespace,
+						UID:             types.UID("svc-" + v.name),
+						OwnerReferences: []metav1.OwnerReference{owner},
+					},
+				}
+				if v.svcNotOwned {
+					svc.OwnerReferences = nil
+				}
+				objs = append(objs, dep, svc)
+
+				listedDep := *dep.DeepCopy()
+				if v.staleUID {
+					listedDep.UID = "dep-" + types.UID(v.name) + "-old"
+				}
+				listed = append(listed, listedDep)
+			}
+			if len(tt.routed) > 0 {
+				paths := []networkingv1.HTTPIngressPath{}
+				for _, v := range tt.routed {
+					paths = append(paths, networkingv1.HTTPIngressPath{
+						Backend: networkingv1.IngressBackend{
+							Service: &networkingv1.IngressServiceBackend{Name: serviceName(v, app.Name)},
+						},
+					})
+				}
+				objs = append(objs, &networkingv1.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            app.Name + "-ingress",
+						Namespace:       app.Namespace,
+						OwnerReferences: []metav1.OwnerReference{owner},
+					},
+					Spec: networkingv1.IngressSpec{
+						Rules: []networkingv1.IngressRule{{
+							IngressRuleValue: networkingv1.IngressRuleValue{
+								HTTP: &networkingv1.HTTPIngressRuleValue{Paths: paths},
+							},
+						}},
+					},
+				})
+			}
+
+			scheme := runtime.NewScheme()
+			if err := clientgoscheme.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			if err := appsv1alpha1.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			c := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(objs...).
+				WithInterceptorFuncs(interceptor.Funcs{
+					// the fake client only checks the resourceVersion precondition, the API
+					// server also rejects a delete whose UID does not match
+					Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+						deleteOpts := &client.DeleteOptions{}
+						deleteOpts.ApplyOptions(opts)
+						if deleteOpts.Preconditions == nil || deleteOpts.Preconditions.UID == nil {
+							t.Errorf("delete of %s without a UID precondition", obj.GetName())
+							return c.Delete(ctx, obj, opts...)
+						}
+						current := obj.DeepCopyObject().(client.Object)
+						if err := c.Get(ctx, client.ObjectKeyFromObject(obj), current); err != nil {
+							return err
+						}
+						if current.GetUID() != *deleteOpts.Preconditions.UID {
+							return apierrors.NewConflict(
+								appsv1.Resource("deployments"), obj.GetName(), nil)
+						}
+						return c.Delete(ctx, obj, opts...)
+					},
+				}).
+				Build()
+			r := &SimpleapiReconciler{Client: c, Scheme: scheme}
+
+			requeue, cond, err := r.cleanupOldDeployments(context.Background(), app, listed, tt.retained, now)
+			if err != nil {
+				t.Fatalf("cleanupOldDeployments() error = %v", err)
+			}
+			if requeue != tt.wantRequeue {
+				t.Errorf("cleanupOldDeployments() requeue = %v, want %v", requeue, tt.wantRequeue)
+			}
+			if cond.Status != tt.wantStatus {
+				t.Errorf("cleanupOldDeployments() condition = %s (%s), want %s", cond.Status, cond.Message, tt.wantStatus)
+			}
+
+			var deployments appsv1.DeploymentList
+			if err := c.List(context.Background(), &deployments); err != nil {
+				t.Fatal(err)
+			}
+			present := []string{}
+			for _, dep := range deployments.Items {
+				version := dep.Labels["version"]
+				present = append(present, version)
+				if got := dep.Annotations[retireAtAnnotation]; got != tt.wantRetire[version] {
+					t.Errorf("version %s retire-at = %q, want %q", version, got, tt.wantRetire[version])
+				}
+			}
+			slices.Sort(present)
+			if !slices.Equal(present, tt.wantPresent) {
+				t.Errorf("remaining versions = %v, want %v", present, tt.wantPresent)
+			}
+
+			var services corev1.ServiceList
+			if err := c.List(context.Background(), &services); err != nil {
+				t.Fatal(err)
+			}
+			if len(services.Items) != len(tt.wantPresent) {
+				t.Errorf("remaining services = %d, want %d", len(services.Items), len(tt.wantPresent))
+			}
+		})
+	}
+}
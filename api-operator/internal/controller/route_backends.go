@@ -0,0 +1,65 @@
+package controller
+
+import (
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+)
+
+const (
+	strategyPathPerVersion string = "pathPerVersion"
+	strategyBlueGreen      string = "blueGreen"
+)
+
+// routeBackend is one path of the generated Ingress or HTTPRoute and the version Service behind it
+type routeBackend struct {
+	Version string
+	Path    string
+	// Host overrides the ingressHostName, it is used for the blueGreen preview hostname
+	Host string
+	// Headers are set on the responses of the path, such as the deprecation headers
+	Headers []responseHeader
+}
+
+type responseHeader struct {
+	Name  string
+	Value string
+}
+
+// routeBackends returns the paths exposed for the routed versions, the version path of every
+// version with the pathPerVersion strategy, or the active and preview paths with blueGreen
+func routeBackends(SimpleAPIApp *appsv1alpha1.Simpleapi, versions []string) []routeBackend {
+	if SimpleAPIApp.Spec.Strategy != strategyBlueGreen {
+		backends := make([]routeBackend, len(versions))
+		for i, ver := range versions {
+			backends[i] = routeBackend{Version: ver, Path: versionPath(SimpleAPIApp, ver)}
+		}
+		return backends
+	}
+
+	state := SimpleAPIApp.Status.BlueGreen
+	if state == nil || state.ActiveVersion == "" {
+		return nil
+	}
+	backends := []routeBackend{{Version: state.ActiveVersion, Path: "/api"}}
+	if state.PreviewVersion != "" {
+		preview := routeBackend{Version: state.PreviewVersion, Path: previewPath(SimpleAPIApp)}
+		if SimpleAPIApp.Spec.BlueGreen != nil && SimpleAPIApp.Spec.BlueGreen.PreviewHostName != "" {
+			preview.Host = SimpleAPIApp.Spec.BlueGreen.PreviewHostName
+			preview.Path = "/api"
+		}
+		backends = append(backends, preview)
+	}
+	return backends
+}
+
+// backendVersions returns the distinct versions behind the backends, in route order
+func backendVersions(backends []routeBackend) []string {
+	versions := []string{}
+	seen := map[string]bool{}
+	for _, backend := range backends {
+		if !seen[backend.Version] {
+			seen[backend.Version] = true
+			versions = append(versions, backend.Version)
+		}
+	}
+	return versions
+}
@@ -0,0 +1,422 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+)
+
+// updateHTTPRouteStatus copies the Accepted and ResolvedRefs results the Gateway wrote on the
+// generated HTTPRoute into the Simpleapi conditions and publishes the version URLs
+func (r *SimpleapiReconciler) updateHTTPRouteStatus(
+	ctx context.Context,
+	backends []routeBackend,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) error {
+	httproute := &gatewayv1.HTTPRoute{}
+	err := r.Get(
+		ctx,
+		client.ObjectKey{Namespace: SimpleAPIApp.Namespace, Name: getHTTPRouteName(SimpleAPIApp)},
+		httproute,
+	)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	routes := []routeResult{{getHTTPRouteName(SimpleAPIApp), httproute.Status.RouteStatus, httproute.Generation}}
+	// the blueGreen preview with its own hostname has a second HTTPRoute
+	for _, backend := range backends {
+		if backend.Host == "" {
+			continue
+		}
+		preview := &gatewayv1.HTTPRoute{}
+		err := r.Get(
+			ctx,
+			client.ObjectKey{Namespace: SimpleAPIApp.Namespace, Name: getPreviewHTTPRouteName(SimpleAPIApp)},
+			preview,
+		)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		routes = append(routes, routeResult{getPreviewHTTPRouteName(SimpleAPIApp), preview.Status.RouteStatus, preview.Generation})
+		break
+	}
+
+	accepted := setRouteConditions(SimpleAPIApp, routes...)
+	scheme, host, err := r.gatewayAddress(ctx, SimpleAPIApp)
+	if err != nil {
+		return err
+	}
+	setVersionURLs(SimpleAPIApp, accepted, scheme, host, backends)
+	return nil
+}
+
+// updateGRPCRouteStatus copies the Accepted and ResolvedRefs results of the generated GRPCRoute
+// into the Simpleapi conditions and publishes the address of the versions. gRPC clients pick
+// the version by service name or header, so all versions share the host and the URLs have no path.
+func (r *SimpleapiReconciler) updateGRPCRouteStatus(
+	ctx context.Context,
+	backends []routeBackend,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) error {
+	grpcroute := &gatewayv1.GRPCRoute{}
+	err := r.Get(
+		ctx,
+		client.ObjectKey{Namespace: SimpleAPIApp.Namespace, Name: getGRPCRouteName(SimpleAPIApp)},
+		grpcroute,
+	)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	accepted := setRouteConditions(
+		SimpleAPIApp,
+		routeResult{getGRPCRouteName(SimpleAPIApp), grpcroute.Status.RouteStatus, grpcroute.Generation},
+	)
+	scheme, host, err := r.gatewayAddress(ctx, SimpleAPIApp)
+	if err != nil {
+		return err
+	}
+	grpcBackends := make([]routeBackend, len(backends))
+	for i, backend := range backends {
+		grpcBackends[i] = backend
+		grpcBackends[i].Path = ""
+		grpcBackends[i].Host = ""
+	}
+	setVersionURLs(SimpleAPIApp, accepted, scheme, host, grpcBackends)
+	return nil
+}
+
+// updateL4RouteStatus copies the Accepted and ResolvedRefs results of the generated TCPRoute or
+// TLSRoute into the Simpleapi conditions and publishes the address of the current version
+func (r *SimpleapiReconciler) updateL4RouteStatus(
+	ctx context.Context,
+	backends []routeBackend,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) error {
+	var route routeResult
+	scheme := "tcp"
+	if SimpleAPIApp.Spec.IngressType == "tlsroute" {
+		scheme = "tls"
+		tlsroute := &gatewayv1alpha2.TLSRoute{}
+		err := r.Get(
+			ctx,
+			client.ObjectKey{Namespace: SimpleAPIApp.Namespace, Name: getTLSRouteName(SimpleAPIApp)},
+			tlsroute,
+		)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		route = routeResult{getTLSRouteName(SimpleAPIApp), tlsroute.Status.RouteStatus, tlsroute.Generation}
+	} else {
+		tcproute := &gatewayv1alpha2.TCPRoute{}
+		err := r.Get(
+			ctx,
+			client.ObjectKey{Namespace: SimpleAPIApp.Namespace, Name: getTCPRouteName(SimpleAPIApp)},
+			tcproute,
+		)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		route = routeResult{getTCPRouteName(SimpleAPIApp), tcproute.Status.RouteStatus, tcproute.Generation}
+	}
+
+	accepted := setRouteConditions(SimpleAPIApp, route)
+	_, host, err := r.gatewayAddress(ctx, SimpleAPIApp)
+	if err != nil {
+		return err
+	}
+	current := []routeBackend{}
+	if backend, ok := currentBackend(SimpleAPIApp, backends); ok {
+		backend.Path = ""
+		current = append(current, backend)
+	}
+	setVersionURLs(SimpleAPIApp, accepted, scheme, host, current)
+	return nil
+}
+
+// routeResult is the status the Gateway wrote on one generated route
+type routeResult struct {
+	name       string
+	status     gatewayv1.RouteStatus
+	generation int64
+}
+
+// setRouteConditions sets the RouteAccepted and RouteResolvedRefs conditions from the status
+// of the routes and reports whether all of them are accepted
+func setRouteConditions(SimpleAPIApp *appsv1alpha1.Simpleapi, routes ...routeResult) bool {
+	accepted := routesCondition(
+		routes,
+		gatewayv1.RouteConditionAccepted,
+		appsv1alpha1.ConditionRouteAccepted,
+		SimpleAPIApp,
+	)
+	resolvedRefs := routesCondition(
+		routes,
+		gatewayv1.RouteConditionResolvedRefs,
+		appsv1alpha1.ConditionRouteResolvedRefs,
+		SimpleAPIApp,
+	)
+	meta.SetStatusCondition(&SimpleAPIApp.Status.Conditions, accepted)
+	meta.SetStatusCondition(&SimpleAPIApp.Status.Conditions, resolvedRefs)
+	return accepted.Status == metav1.ConditionTrue
+}
+
+// routesCondition folds the routeCondition of every route, a route that reports False wins
+// over one that is Unknown
+func routesCondition(
+	routes []routeResult,
+	routeCondition gatewayv1.RouteConditionType,
+	conditionType string,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) metav1.Condition {
+	rank := map[metav1.ConditionStatus]int{
+		metav1.ConditionTrue:    0,
+		metav1.ConditionUnknown: 1,
+		metav1.ConditionFalse:   2,
+	}
+	var cond metav1.Condition
+	for i, route := range routes {
+		c := routeParentsCondition(route.status, route.generation, routeCondition, conditionType, SimpleAPIApp)
+		if len(routes) > 1 && c.Status != metav1.ConditionTrue {
+			c.Message = route.name + ": " + c.Message
+		}
+		if i == 0 || rank[c.Status] > rank[cond.Status] {
+			cond = c
+		}
+	}
+	return cond
+}
+
+// gatewayAddress derives the scheme and host of the routes from the first parent,
+// the hostname on the spec always wins
+func (r *SimpleapiReconciler) gatewayAddress(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) (string, string, error) {
+	scheme, host := "http", SimpleAPIApp.Spec.IngressHostName
+	parentRef := constructParentRefs(SimpleAPIApp)[0]
+	gwNamespace := SimpleAPIApp.Namespace
+	if parentRef.Namespace != nil {
+		gwNamespace = string(*parentRef.Namespace)
+	}
+	gateway := &gatewayv1.Gateway{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: gwNamespace, Name: string(parentRef.Name)}, gateway)
+	if err != nil && !errors.IsNotFound(err) {
+		return "", "", err
+	}
+	for _, listener := range gateway.Spec.Listeners {
+		if parentRef.SectionName != nil && listener.Name != *parentRef.SectionName {
+			continue
+		}
+		if parentRef.Port != nil && listener.Port != *parentRef.Port {
+			continue
+		}
+		if listener.Protocol == gatewayv1.HTTPSProtocolType {
+			scheme = "https"
+		}
+		if host == "" && listener.Hostname != nil && !strings.HasPrefix(string(*listener.Hostname), "*") {
+			host = string(*listener.Hostname)
+		}
+		if host == "" && len(gateway.Status.Addresses) > 0 {
+			host = gateway.Status.Addresses[0].Value
+		}
+		if host != "" && listener.Port != 80 && listener.Port != 443 {
+			host = fmt.Sprintf("%s:%d", host, listener.Port)
+		}
+		break
+	}
+	return scheme, host, nil
+}
+
+// updateIngressStatus reports whether the generated Ingress got a load balancer address
+// and publishes the version URLs
+func (r *SimpleapiReconciler) updateIngressStatus(
+	ctx context.Context,
+	backends []routeBackend,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) error {
+	ingress := &networkingv1.Ingress{}
+	err := r.Get(
+		ctx,
+		client.ObjectKey{Namespace: SimpleAPIApp.Namespace, Name: getIngressName(SimpleAPIApp)},
+		ingress,
+	)
+	if errors.IsNotFound(err) {
+		// without a main Ingress the address comes from the Ingress of a deprecated version
+		var ingressList networkingv1.IngressList
+		if err := r.List(
+			ctx,
+			&ingressList,
+			client.InNamespace(SimpleAPIApp.Namespace),
+			client.MatchingLabels{simpleapiLabel: SimpleAPIApp.Name},
+			client.HasLabels{deprecatedVersionLabel},
+		); err != nil {
+			return err
+		}
+		if len(ingressList.Items) > 0 {
+			ingress = &ingressList.Items[0]
+		}
+	} else if err != nil {
+		return err
+	}
+
+	accepted := metav1.Condition{
+		Type:               appsv1alpha1.ConditionRouteAccepted,
+		Status:             metav1.ConditionFalse,
+		Reason:             "AddressPending",
+		Message:            "the Ingress has no load balancer address yet",
+		ObservedGeneration: SimpleAPIApp.Generation,
+	}
+	host := SimpleAPIApp.Spec.IngressHostName
+	if lb := ingress.Status.LoadBalancer.Ingress; len(lb) > 0 {
+		accepted.Status = metav1.ConditionTrue
+		accepted.Reason = "AddressAssigned"
+		accepted.Message = "the Ingress has a load balancer address"
+		if host == "" {
+			host = lb[0].Hostname
+		}
+		if host == "" {
+			host = lb[0].IP
+		}
+	}
+	meta.SetStatusCondition(&SimpleAPIApp.Status.Conditions, accepted)
+	meta.RemoveStatusCondition(&SimpleAPIApp.Status.Conditions, appsv1alpha1.ConditionRouteResolvedRefs)
+
+	setVersionURLs(SimpleAPIApp, accepted.Status == metav1.ConditionTrue, ingressScheme(ingress, host), host, backends)
+	return nil
+}
+
+// ingressScheme is https when a spec.tls entry of the Ingress covers the host
+func ingressScheme(ingress *networkingv1.Ingress, host string) string {
+	for _, tls := range ingress.Spec.TLS {
+		if len(tls.Hosts) == 0 || slices.Contains(tls.Hosts, host) {
+			return "https"
+		}
+	}
+	return "http"
+}
+
+// routeParentsCondition folds the routeCondition of every parent in the route status into
+// one Simpleapi condition, any parent that reports False wins, missing parents are Unknown
+func routeParentsCondition(
+	routeStatus gatewayv1.RouteStatus,
+	generation int64,
+	routeCondition gatewayv1.RouteConditionType,
+	conditionType string,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) metav1.Condition {
+	cond := metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionTrue,
+		Reason:             string(routeCondition),
+		Message:            fmt.Sprintf("all parents report %s", routeCondition),
+		ObservedGeneration: SimpleAPIApp.Generation,
+	}
+	pending := []string{}
+
+	for _, ref := range constructParentRefs(SimpleAPIApp) {
+		var parentCond *metav1.Condition
+		for _, parent := range routeStatus.Parents {
+			if sameParentRef(ref, parent.ParentRef, SimpleAPIApp.Namespace) {
+				parentCond = meta.FindStatusCondition(parent.Conditions, string(routeCondition))
+				break
+			}
+		}
+		if parentCond == nil || parentCond.ObservedGeneration < generation {
+			pending = append(pending, string(ref.Name))
+			continue
+		}
+		if parentCond.Status != metav1.ConditionTrue {
+			cond.Status = metav1.ConditionFalse
+			cond.Reason = parentCond.Reason
+			cond.Message = fmt.Sprintf("gateway %s: %s", ref.Name, parentCond.Message)
+			return cond
+		}
+	}
+
+	if len(pending) > 0 {
+		cond.Status = metav1.ConditionUnknown
+		cond.Reason = "Pending"
+		cond.Message = fmt.Sprintf(
+			"waiting for %s from gateway %s",
+			routeCondition,
+			strings.Join(pending, ", "),
+		)
+	}
+	return cond
+}
+
+func sameParentRef(a, b gatewayv1.ParentReference, routeNamespace string) bool {
+	nsA, nsB := routeNamespace, routeNamespace
+	if a.Namespace != nil {
+		nsA = string(*a.Namespace)
+	}
+	if b.Namespace != nil {
+		nsB = string(*b.Namespace)
+	}
+	if a.Name != b.Name || nsA != nsB {
+		return false
+	}
+	if (a.SectionName == nil) != (b.SectionName == nil) ||
+		(a.SectionName != nil && *a.SectionName != *b.SectionName) {
+		return false
+	}
+	if (a.Port == nil) != (b.Port == nil) || (a.Port != nil && *a.Port != *b.Port) {
+		return false
+	}
+	return true
+}
+
+// setVersionURLs publishes one URL per routed path and sets the Ready condition
+func setVersionURLs(
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+	accepted bool,
+	scheme string,
+	host string,
+	backends []routeBackend,
+) {
+	SimpleAPIApp.Status.URLs = nil
+	for _, backend := range backends {
+		backendHost := host
+		if backend.Host != "" {
+			backendHost = backend.Host
+		}
+		if backendHost == "" {
+			continue
+		}
+		SimpleAPIApp.Status.URLs = append(SimpleAPIApp.Status.URLs, appsv1alpha1.VersionURL{
+			Version: backend.Version,
+			URL:     fmt.Sprintf("%s://%s%s", scheme, backendHost, backend.Path),
+		})
+	}
+
+	ready := metav1.Condition{
+		Type:               appsv1alpha1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "URLsPublished",
+		Message:            "all routed versions are reachable",
+		ObservedGeneration: SimpleAPIApp.Generation,
+	}
+	switch {
+	case !accepted:
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "RouteNotAccepted"
+		ready.Message = "the route is not accepted yet"
+	case len(SimpleAPIApp.Status.URLs) == 0:
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "AddressPending"
+		ready.Message = "no external address is known for the route"
+	}
+	meta.SetStatusCondition(&SimpleAPIApp.Status.Conditions, ready)
+}
@@ -19,9 +19,12 @@ package controller
 import (
 	"context"
 	"fmt"
+	"maps"
 	"time"
 
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -29,22 +32,56 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 
 	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
 )
 
+const (
+	// simpleapiLabel marks every object generated for a Simpleapi with its name
+	simpleapiLabel = "apps.api.test/simpleapi"
+)
+
+const (
+	// routeStatusRequeue is how often the route status is polled until the Simpleapi is Ready
+	routeStatusRequeue = 30 * time.Second
+	// availabilityRequeue is how often a new version is checked until it is available
+	availabilityRequeue = 10 * time.Second
+)
+
 // SimpleapiReconciler reconciles a Simpleapi object
 type SimpleapiReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	APIs   DiscoveredAPIs
+	// WebhookEnabled is set when the validating webhook runs, spec.rbacRules are only granted
+	// then as the webhook checks that the requesting user holds them
+	WebhookEnabled bool
 }
 
 // +kubebuilder:rbac:groups=apps.api.test,resources=simpleapis,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps.api.test,resources=simpleapis/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=apps.api.test,resources=simpleapis/finalizers,verbs=update
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=services;serviceaccounts;configmaps,verbs=get;list;watch;create;update;patch;delete
+// escalate and bind let the Role carry rbacRules the manager does not hold itself, the webhook
+// refuses rules the user creating or updating the Simpleapi does not hold
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;update;patch;delete;escalate;bind
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways,verbs=get;list;watch
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=grpcroutes,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=tcproutes;tlsroutes,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses;networkpolicies,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -73,119 +110,527 @@ func (r *SimpleapiReconciler) Reconcile(
 		logger.Error(err, "Failed to get AppVersion")
 		return ctrl.Result{}, err
 	}
-	sa := r.constructServiceAccount(SimpleapiApp)
-	if err := controllerutil.SetControllerReference(&SimpleapiApp, sa, r.Scheme); err != nil {
+	if isPaused(&SimpleapiApp) {
+		return r.reconcilePaused(ctx, &SimpleapiApp)
+	}
+	meta.RemoveStatusCondition(&SimpleapiApp.Status.Conditions, appsv1alpha1.ConditionPaused)
+
+	saCond, err := r.reconcileServiceAccount(ctx, &SimpleapiApp)
+	if err != nil {
+		logger.Error(err, "Failed to reconcile Service account", "ServiceAccount", serviceAccountName(&SimpleapiApp))
 		return ctrl.Result{}, err
 	}
+	meta.SetStatusCondition(&SimpleapiApp.Status.Conditions, saCond)
 
-	if err := r.Create(ctx, sa); err != nil {
-		if errors.IsAlreadyExists(err) {
-			logger.Info(
-				"Service Account exists, skipping creation",
-				"ServiceAccount",
-				sa.Name,
-			)
-		} else {
-			logger.Error(err, "Failed to create Service account", "ServiceAccount", sa.Name)
-			return ctrl.Result{}, err
-		}
+	rbacCond, err := r.reconcileRBAC(ctx, &SimpleapiApp)
+	if err != nil {
+		logger.Error(err, "Failed to reconcile Role and RoleBinding", "Role", getRoleName(&SimpleapiApp))
+		return ctrl.Result{}, err
+	}
+	if len(SimpleapiApp.Spec.RBACRules) > 0 {
+		meta.SetStatusCondition(&SimpleapiApp.Status.Conditions, rbacCond)
 	} else {
-		logger.Info("Successfully created new deployment", "Deployment", sa.Name)
+		meta.RemoveStatusCondition(&SimpleapiApp.Status.Conditions, appsv1alpha1.ConditionRBACReady)
 	}
 
-	// List existing Deployments for the API using the label "app=my-api from the constants it is subject to change"
-	var deploymentList appsv1.DeploymentList
-	if err := r.List(ctx, &deploymentList, client.MatchingLabels{"app": SimpleapiApp.Labels["app"]}); err != nil {
-		logger.Error(err, "Failed to list Deployments")
+	if err := r.reconcileNetworkPolicy(ctx, &SimpleapiApp); err != nil {
+		logger.Error(err, "Failed to reconcile NetworkPolicy", "NetworkPolicy", getNetworkPolicyName(&SimpleapiApp))
 		return ctrl.Result{}, err
 	}
-	// Always create new deployment with unique timestamp
-	timestamp := time.Now().Unix()
 
-	// adding new deployment, this is only construct
-	newDeployment := r.constructDeployment(SimpleapiApp, timestamp)
-	// setting appVersion as owner for garbage collection best practices
-	if err := controllerutil.SetControllerReference(&SimpleapiApp, newDeployment, r.Scheme); err != nil {
+	monitorCond, err := r.reconcileServiceMonitor(ctx, &SimpleapiApp)
+	if err != nil {
+		logger.Error(err, "Failed to reconcile ServiceMonitor", "ServiceMonitor", getServiceMonitorName(&SimpleapiApp))
 		return ctrl.Result{}, err
 	}
-
-	// Check if deployment already exists before creating
-	if err := r.Create(ctx, newDeployment); err != nil {
-		if errors.IsAlreadyExists(err) {
-			logger.Info(
-				"Deployment already exists, skipping creation",
-				"Deployment",
-				newDeployment.Name,
-			)
-		} else {
-			logger.Error(err, "Failed to create Deployment", "Deployment", newDeployment.Name)
-			return ctrl.Result{}, err
-		}
+	if SimpleapiApp.Spec.Monitoring != nil && SimpleapiApp.Spec.Monitoring.Enabled {
+		meta.SetStatusCondition(&SimpleapiApp.Status.Conditions, monitorCond)
 	} else {
-		logger.Info("Successfully created new deployment", "Deployment", newDeployment.Name)
+		meta.RemoveStatusCondition(&SimpleapiApp.Status.Conditions, appsv1alpha1.ConditionServiceMonitorReady)
 	}
 
-	// Create corresponding Service.
-	newService := r.constructService(SimpleapiApp, timestamp)
-	if err := controllerutil.SetControllerReference(&SimpleapiApp, newService, r.Scheme); err != nil {
+	// List existing Deployments controlled by this Simpleapi, other Simpleapis sharing the app label are left alone
+	var deploymentList appsv1.DeploymentList
+	if err := r.listOwnedDeployments(ctx, &SimpleapiApp, &deploymentList); err != nil {
+		logger.Error(err, "Failed to list Deployments")
 		return ctrl.Result{}, err
 	}
+	// the preRollout hook has to succeed before the Deployment of a new version is created
+	preRolloutDone, err := r.preRolloutDone(ctx, &SimpleapiApp)
+	if err != nil {
+		logger.Error(err, "Failed to run preRollout hook")
+		return ctrl.Result{}, err
+	}
+	// an invalid podTemplatePatch blocks the rollout, the webhook rejects it at admission when enabled
+	podTemplateValid := true
+	if SimpleapiApp.Spec.PodTemplatePatch != nil {
+		podTemplateCond := r.podTemplateCondition(&SimpleapiApp)
+		meta.SetStatusCondition(&SimpleapiApp.Status.Conditions, podTemplateCond)
+		podTemplateValid = podTemplateCond.Status == metav1.ConditionTrue
+	} else {
+		meta.RemoveStatusCondition(&SimpleapiApp.Status.Conditions, appsv1alpha1.ConditionPodTemplateValid)
+	}
 
-	// Check if service already exists before creating
-	if err := r.Create(ctx, newService); err != nil {
-		if errors.IsAlreadyExists(err) {
-			logger.Info("Service already exists, skipping creation", "Service", newService.Name)
-		} else {
-			logger.Error(err, "Failed to create Service", "Service", newService.Name)
+	// the restricted profile refuses to roll out pods the namespace Pod Security level rejects
+	podSecurityAdmitted := true
+	if restrictedProfile(&SimpleapiApp) {
+		podSecurityCond, err := r.checkPodSecurity(ctx, &SimpleapiApp)
+		if err != nil {
+			logger.Error(err, "Failed to check the namespace Pod Security level")
 			return ctrl.Result{}, err
 		}
+		meta.SetStatusCondition(&SimpleapiApp.Status.Conditions, podSecurityCond)
+		podSecurityAdmitted = podSecurityCond.Status == metav1.ConditionTrue
 	} else {
-		logger.Info("Successfully created new service", "Service", newService.Name)
+		meta.RemoveStatusCondition(&SimpleapiApp.Status.Conditions, appsv1alpha1.ConditionPodSecurityAdmitted)
+	}
+	// versions such as v1.2 and v1-2 share the DNS-safe name, the second one is not rolled out
+	versionNameCond := versionNameCondition(&SimpleapiApp, deploymentList.Items)
+	meta.SetStatusCondition(&SimpleapiApp.Status.Conditions, versionNameCond)
+	versionNameUnique := versionNameCond.Status == metav1.ConditionTrue
+	if preRolloutDone && podTemplateValid && podSecurityAdmitted && versionNameUnique {
+		if err := r.createVersion(ctx, &SimpleapiApp); err != nil {
+			return ctrl.Result{}, err
+		}
 	}
 
 	// Re-list deployments to capture the new state.
-	if err := r.List(ctx, &deploymentList, client.MatchingLabels{"app": SimpleapiApp.Labels["app"]}); err != nil {
+	if err := r.listOwnedDeployments(ctx, &SimpleapiApp, &deploymentList); err != nil {
 		return ctrl.Result{}, err
 	}
-	// Extract last two versions based on timestamps.
-	sortedDeployments := sortDeploymentsByTimestamp(deploymentList.Items)
-	latestVersions := extractLatestVersions(sortedDeployments)
+	sortedDeployments := sortDeployments(&SimpleapiApp, deploymentList.Items)
+	if err := r.reconcileVersionSelectors(ctx, &SimpleapiApp, sortedDeployments); err != nil {
+		logger.Error(err, "Failed to reconcile the version Service selectors")
+		return ctrl.Result{}, err
+	}
+
+	// the spec version is only routed once its postRollout hook succeeded
+	if SimpleapiApp.Spec.Hooks == nil {
+		SimpleapiApp.Status.Hooks = nil
+		meta.RemoveStatusCondition(&SimpleapiApp.Status.Conditions, appsv1alpha1.ConditionHooksSucceeded)
+	}
+	postRolloutDone, err := r.postRolloutDone(ctx, &SimpleapiApp, sortedDeployments)
+	if err != nil {
+		logger.Error(err, "Failed to run postRollout hook")
+		return ctrl.Result{}, err
+	}
+
+	// a new version joins the routes once its Deployment is available, until then the
+	// previous route set stays in place
+	versionAvailable := specVersionAvailable(&SimpleapiApp, sortedDeployments)
+	versionRoutable := versionAvailable && postRolloutDone
+	setVersionAvailableCondition(&SimpleapiApp, versionAvailable)
+	// the progress deadline decides that a rollout failed, the Deployment watch wakes us up again
+	rolloutFailed := specVersionFailed(&SimpleapiApp, sortedDeployments)
+	setDegradedCondition(&SimpleapiApp, rolloutFailed)
 
-	// Delete older versions beyond the latest two.
-	r.cleanupOldDeployments(ctx, sortedDeployments)
+	// Extract the routable versions picked by the version policy, versions past their sunset are pruned.
+	now := time.Now()
+	latestVersions, retainedVersions := selectVersions(
+		&SimpleapiApp,
+		withoutSunsetVersions(&SimpleapiApp, sortedDeployments, now),
+		versionRoutable,
+	)
+
+	// blueGreen keeps the active, preview and recently replaced versions instead of the latest two
+	var requeueAfter time.Duration
+	if !versionAvailable && !rolloutFailed {
+		requeueAfter = availabilityRequeue
+	}
+	if SimpleapiApp.Spec.Strategy == strategyBlueGreen {
+		var promotionRequeue time.Duration
+		retainedVersions, promotionRequeue = advanceBlueGreen(&SimpleapiApp, versionRoutable, now)
+		requeueAfter = minRequeue(requeueAfter, promotionRequeue)
+	} else {
+		SimpleapiApp.Status.BlueGreen = nil
+	}
+	backends := routeBackends(&SimpleapiApp, latestVersions)
+	deprecateBackends(&SimpleapiApp, backends, now)
+	requeueAfter = minRequeue(requeueAfter, nextVersionEvent(&SimpleapiApp, now))
 
 	// Reconcile Ingress paths to reflect the latest two versions.
 	switch SimpleapiApp.Spec.IngressType {
 	case "ingress":
-		if err := r.reconcileIngress(ctx, latestVersions, SimpleapiApp.Namespace, &SimpleapiApp); err != nil {
+		deprecationCond, err := r.reconcileIngress(ctx, backends, SimpleapiApp.Namespace, &SimpleapiApp)
+		if err != nil {
 			logger.Error(err, "Failed to reconcile Ingress")
 			return ctrl.Result{}, err
 		}
+		if deprecationCond != nil {
+			meta.SetStatusCondition(&SimpleapiApp.Status.Conditions, *deprecationCond)
+		} else {
+			meta.RemoveStatusCondition(&SimpleapiApp.Status.Conditions, appsv1alpha1.ConditionDeprecationApplied)
+		}
+		meta.RemoveStatusCondition(&SimpleapiApp.Status.Conditions, appsv1alpha1.ConditionRouteAllowed)
+		if err := r.updateIngressStatus(ctx, backends, &SimpleapiApp); err != nil {
+			logger.Error(err, "Failed to read Ingress status")
+			return ctrl.Result{}, err
+		}
 	case "httproute":
-		if err := r.reconcileHTTPRoute(ctx, latestVersions, SimpleapiApp.Namespace, &SimpleapiApp); err != nil {
+		if !r.APIs.HTTPRoute {
+			return r.routeUnsupported(ctx, &SimpleapiApp, "HTTPRoute")
+		}
+		if err := r.reconcileHTTPRoute(ctx, backends, SimpleapiApp.Namespace, &SimpleapiApp); err != nil {
 			logger.Error(err, "Failed to Reconcile httproute")
 			return ctrl.Result{}, err
 		}
+		allowedCond, err := r.checkAllowedRoutes(ctx, &SimpleapiApp)
+		if err != nil {
+			logger.Error(err, "Failed to check Gateway allowedRoutes")
+			return ctrl.Result{}, err
+		}
+		meta.SetStatusCondition(&SimpleapiApp.Status.Conditions, allowedCond)
+		if err := r.updateHTTPRouteStatus(ctx, backends, &SimpleapiApp); err != nil {
+			logger.Error(err, "Failed to read httproute status")
+			return ctrl.Result{}, err
+		}
+	case "grpcroute":
+		if !r.APIs.GRPCRoute {
+			return r.routeUnsupported(ctx, &SimpleapiApp, "GRPCRoute")
+		}
+		if err := r.reconcileGRPCRoute(ctx, backends, SimpleapiApp.Namespace, &SimpleapiApp); err != nil {
+			logger.Error(err, "Failed to Reconcile grpcroute")
+			return ctrl.Result{}, err
+		}
+		allowedCond, err := r.checkAllowedRoutes(ctx, &SimpleapiApp)
+		if err != nil {
+			logger.Error(err, "Failed to check Gateway allowedRoutes")
+			return ctrl.Result{}, err
+		}
+		meta.SetStatusCondition(&SimpleapiApp.Status.Conditions, allowedCond)
+		if err := r.updateGRPCRouteStatus(ctx, backends, &SimpleapiApp); err != nil {
+			logger.Error(err, "Failed to read grpcroute status")
+			return ctrl.Result{}, err
+		}
+	case "tcproute", "tlsroute":
+		// raw TCP and TLS passthrough only expose the current version
+		if !r.APIs.hasRoute(routeKind(&SimpleapiApp)) {
+			return r.routeUnsupported(ctx, &SimpleapiApp, routeKind(&SimpleapiApp))
+		}
+		if err := r.reconcileL4Route(ctx, backends, SimpleapiApp.Namespace, &SimpleapiApp); err != nil {
+			logger.Error(err, "Failed to Reconcile "+SimpleapiApp.Spec.IngressType)
+			return ctrl.Result{}, err
+		}
+		allowedCond, err := r.checkAllowedRoutes(ctx, &SimpleapiApp)
+		if err != nil {
+			logger.Error(err, "Failed to check Gateway allowedRoutes")
+			return ctrl.Result{}, err
+		}
+		meta.SetStatusCondition(&SimpleapiApp.Status.Conditions, allowedCond)
+		if err := r.updateL4RouteStatus(ctx, backends, &SimpleapiApp); err != nil {
+			logger.Error(err, "Failed to read "+SimpleapiApp.Spec.IngressType+" status")
+			return ctrl.Result{}, err
+		}
 	default:
 		logger.Error(
 			fmt.Errorf("error"),
-			"Error missing spec value for IngressType either httproute or ingress",
+			"Error missing spec value for IngressType either httproute, grpcroute, tcproute, tlsroute or ingress",
 		)
 		return ctrl.Result{}, errors.NewBadRequest(
-			"Error missing spec value for IngressType either httproute or ingress",
+			"Error missing spec value for IngressType either httproute, grpcroute, tcproute, tlsroute or ingress",
 		)
 	}
 
+	// Drain and then delete older versions that are not retained, after the route no longer uses them.
+	pruneRequeueAfter, pruneCond, err := r.cleanupOldDeployments(
+		ctx,
+		&SimpleapiApp,
+		sortedDeployments,
+		retainedVersions,
+		now,
+	)
+	if err != nil {
+		logger.Error(err, "Failed to read the live route before pruning")
+		return ctrl.Result{}, err
+	}
+	requeueAfter = minRequeue(requeueAfter, pruneRequeueAfter)
+	meta.SetStatusCondition(&SimpleapiApp.Status.Conditions, pruneCond)
+	if err := r.cleanupHookJobs(ctx, &SimpleapiApp, retainedVersions); err != nil {
+		logger.Error(err, "Failed to delete old hook jobs")
+		return ctrl.Result{}, err
+	}
+
+	meta.SetStatusCondition(&SimpleapiApp.Status.Conditions, metav1.Condition{
+		Type:               appsv1alpha1.ConditionRouteSupported,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Supported",
+		Message:            "ingressType " + SimpleapiApp.Spec.IngressType + " is supported by the cluster",
+		ObservedGeneration: SimpleapiApp.Generation,
+	})
+	SimpleapiApp.Status.MirroredVersion = mirroredVersion(backendVersions(backends), &SimpleapiApp)
+	if err := r.Status().Update(ctx, &SimpleapiApp); err != nil {
+		logger.Error(err, "Failed to update Simpleapi status")
+		return ctrl.Result{}, err
+	}
+	// route acceptance is watched, but Gateway and load balancer addresses are not
+	if !meta.IsStatusConditionTrue(SimpleapiApp.Status.Conditions, appsv1alpha1.ConditionReady) {
+		requeueAfter = minRequeue(requeueAfter, routeStatusRequeue)
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// routeUnsupported reports that the route kind of the ingressType is not installed, the
+// operator has to be restarted once the CRD is added
+func (r *SimpleapiReconciler) routeUnsupported(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+	kind string,
+) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	meta.SetStatusCondition(&SimpleAPIApp.Status.Conditions, metav1.Condition{
+		Type:               appsv1alpha1.ConditionRouteSupported,
+		Status:             metav1.ConditionFalse,
+		Reason:             "GatewayAPINotInstalled",
+		Message:            "the " + kind + " CRD was not found when the operator started",
+		ObservedGeneration: SimpleAPIApp.Generation,
+	})
+	if err := r.Status().Update(ctx, SimpleAPIApp); err != nil {
+		logger.Error(err, "Failed to update Simpleapi status")
+		return ctrl.Result{}, err
+	}
 	return ctrl.Result{}, nil
 }
 
+func setVersionAvailableCondition(SimpleAPIApp *appsv1alpha1.Simpleapi, available bool) {
+	cond := metav1.Condition{
+		Type:               appsv1alpha1.ConditionVersionAvailable,
+		Status:             metav1.ConditionTrue,
+		Reason:             "MinimumReplicasAvailable",
+		Message:            fmt.Sprintf("version %s is available", SimpleAPIApp.Spec.Version),
+		ObservedGeneration: SimpleAPIApp.Generation,
+	}
+	if !available {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "MinimumReplicasUnavailable"
+		cond.Message = fmt.Sprintf(
+			"version %s has less than %d ready replicas, it is not routed yet",
+			SimpleAPIApp.Spec.Version,
+			minReadyReplicas(SimpleAPIApp),
+		)
+	}
+	meta.SetStatusCondition(&SimpleAPIApp.Status.Conditions, cond)
+}
+
+func (r *SimpleapiReconciler) podTemplateCondition(SimpleAPIApp *appsv1alpha1.Simpleapi) metav1.Condition {
+	cond := metav1.Condition{
+		Type:               appsv1alpha1.ConditionPodTemplateValid,
+		Status:             metav1.ConditionTrue,
+		Reason:             "PatchApplied",
+		Message:            "podTemplatePatch applies to the pod template",
+		ObservedGeneration: SimpleAPIApp.Generation,
+	}
+	if _, err := r.constructDeployment(*SimpleAPIApp, 0); err != nil {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "InvalidPatch"
+		cond.Message = err.Error()
+	}
+	return cond
+}
+
+func setDegradedCondition(SimpleAPIApp *appsv1alpha1.Simpleapi, failed bool) {
+	cond := metav1.Condition{
+		Type:               appsv1alpha1.ConditionDegraded,
+		Status:             metav1.ConditionFalse,
+		Reason:             "RolloutProgressing",
+		Message:            fmt.Sprintf("version %s is within its progress deadline", SimpleAPIApp.Spec.Version),
+		ObservedGeneration: SimpleAPIApp.Generation,
+	}
+	if failed {
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = "ProgressDeadlineExceeded"
+		cond.Message = fmt.Sprintf(
+			"the rollout of version %s made no progress within its deadline",
+			SimpleAPIApp.Spec.Version,
+		)
+	}
+	meta.SetStatusCondition(&SimpleAPIApp.Status.Conditions, cond)
+}
+
+// createVersion creates the Deployment and Service of the spec version when they do not exist
+// and updates the pod template and rollout settings of an existing Deployment in place
+func (r *SimpleapiReconciler) createVersion(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+) error {
+	logger := log.FromContext(ctx)
+
+	// Always create new deployment with unique timestamp
+	timestamp := time.Now().Unix()
+
+	// adding new deployment, this is only construct
+	newDeployment, err := r.constructDeployment(*SimpleAPIApp, timestamp)
+	if err != nil {
+		return err
+	}
+	// setting appVersion as owner for garbage collection best practices
+	if err := controllerutil.SetControllerReference(SimpleAPIApp, newDeployment, r.Scheme); err != nil {
+		return err
+	}
+
+	// Check if deployment already exists before creating
+	if err := r.Create(ctx, newDeployment); err != nil {
+		if errors.IsAlreadyExists(err) {
+			if err := r.updateVersion(ctx, SimpleAPIApp, newDeployment); err != nil {
+				logger.Error(err, "Failed to update Deployment", "Deployment", newDeployment.Name)
+				return err
+			}
+		} else {
+			logger.Error(err, "Failed to create Deployment", "Deployment", newDeployment.Name)
+			return err
+		}
+	} else {
+		logger.Info("Successfully created new deployment", "Deployment", newDeployment.Name)
+	}
+
+	// Create corresponding Service.
+	newService := r.constructService(*SimpleAPIApp, timestamp)
+	if err := controllerutil.SetControllerReference(SimpleAPIApp, newService, r.Scheme); err != nil {
+		return err
+	}
+
+	// Check if service already exists before creating
+	if err := r.Create(ctx, newService); err != nil {
+		if errors.IsAlreadyExists(err) {
+			if err := r.updateVersionService(ctx, SimpleAPIApp, newService); err != nil {
+				logger.Error(err, "Failed to update Service", "Service", newService.Name)
+				return err
+			}
+		} else {
+			logger.Error(err, "Failed to create Service", "Service", newService.Name)
+			return err
+		}
+	} else {
+		logger.Info("Successfully created new service", "Service", newService.Name)
+	}
+
+	return nil
+}
+
+// updateVersion rolls the existing Deployment of the version to the new pod template with its
+// rollout strategy, the selector and the lastDeployedAt annotation are kept, as are the replicas
+// of an autoscaled Deployment
+func (r *SimpleapiReconciler) updateVersion(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+	newDeployment *appsv1.Deployment,
+) error {
+	logger := log.FromContext(ctx)
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(newDeployment), deployment); err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(deployment, SimpleAPIApp) {
+		logger.Info("Deployment exists and is not managed by this Simpleapi, leaving it untouched",
+			"Deployment", deployment.Name)
+		return nil
+	}
+	autoscaled, err := r.autoscaled(ctx, deployment)
+	if err != nil {
+		return err
+	}
+	// an HorizontalPodAutoscaler owns the replicas of the Deployment it targets
+	if !autoscaled {
+		deployment.Spec.Replicas = newDeployment.Spec.Replicas
+	}
+	deployment.Spec.Template = newDeployment.Spec.Template
+	deployment.Spec.Strategy = newDeployment.Spec.Strategy
+	deployment.Spec.MinReadySeconds = newDeployment.Spec.MinReadySeconds
+	deployment.Spec.ProgressDeadlineSeconds = newDeployment.Spec.ProgressDeadlineSeconds
+	deployment.Spec.RevisionHistoryLimit = newDeployment.Spec.RevisionHistoryLimit
+	return r.Update(ctx, deployment)
+}
+
+// autoscaled reports whether a HorizontalPodAutoscaler, such as one created by KEDA, scales the Deployment
+func (r *SimpleapiReconciler) autoscaled(ctx context.Context, deployment *appsv1.Deployment) (bool, error) {
+	var hpaList autoscalingv2.HorizontalPodAutoscalerList
+	if err := r.List(ctx, &hpaList, client.InNamespace(deployment.Namespace)); err != nil {
+		return false, err
+	}
+	for _, hpa := range hpaList.Items {
+		ref := hpa.Spec.ScaleTargetRef
+		if ref.Kind == "Deployment" && ref.Name == deployment.Name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// updateVersionService updates the ports and labels of the existing Service of the version,
+// the selector is moved over by reconcileVersionSelectors
+func (r *SimpleapiReconciler) updateVersionService(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+	newService *corev1.Service,
+) error {
+	service := &corev1.Service{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(newService), service); err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(service, SimpleAPIApp) {
+		return nil
+	}
+	service.Spec.Ports = newService.Spec.Ports
+	if service.Labels == nil {
+		service.Labels = map[string]string{}
+	}
+	maps.Copy(service.Labels, newService.Labels)
+	return r.Update(ctx, service)
+}
+
+// listOwnedDeployments lists the Deployments in the Simpleapi namespace whose controller is the Simpleapi,
+// the app label narrows the list and Deployments of other Simpleapis sharing it are dropped
+func (r *SimpleapiReconciler) listOwnedDeployments(
+	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+	deploymentList *appsv1.DeploymentList,
+) error {
+	if err := r.List(
+		ctx,
+		deploymentList,
+		client.InNamespace(SimpleAPIApp.Namespace),
+		client.MatchingLabels{"app": SimpleAPIApp.Labels["app"]},
+	); err != nil {
+		return err
+	}
+	owned := deploymentList.Items[:0]
+	for _, dep := range deploymentList.Items {
+		if metav1.IsControlledBy(&dep, SimpleAPIApp) {
+			owned = append(owned, dep)
+		}
+	}
+	deploymentList.Items = owned
+	return nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *SimpleapiReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&appsv1alpha1.Simpleapi{}).
 		Owns(&appsv1.Deployment{}).
 		Owns(&corev1.Service{}).
+		Owns(&corev1.ServiceAccount{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&batchv1.Job{}).
+		Owns(&rbacv1.Role{}).
+		Owns(&rbacv1.RoleBinding{}).
 		Owns(&networkingv1.Ingress{}).
-		Owns(&gatewayv1.GatewayClass{}).
-		Complete(r)
+		Owns(&networkingv1.NetworkPolicy{})
+	// watching a kind without its CRD makes the manager fail to start
+	if r.APIs.HTTPRoute {
+		b = b.Owns(&gatewayv1.HTTPRoute{})
+	}
+	if r.APIs.GRPCRoute {
+		b = b.Owns(&gatewayv1.GRPCRoute{})
+	}
+	if r.APIs.TCPRoute {
+		b = b.Owns(&gatewayv1alpha2.TCPRoute{})
+	}
+	if r.APIs.TLSRoute {
+		b = b.Owns(&gatewayv1alpha2.TLSRoute{})
+	}
+	if r.APIs.ServiceMonitor {
+		b = b.Owns(newServiceMonitor())
+	}
+	return b.Complete(r)
 }
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestUpdateVersionReplicas(t *testing.T) {
+	app := testSimpleapi()
+	name := deploymentName("v1", app.Name)
+	hpa := func(kind, target string) *autoscalingv2.HorizontalPodAutoscaler {
+		return &autoscalingv2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "keda-hpa-demo", Namespace: app.Namespace},
+			Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{Kind: kind, Name: target},
+				MaxReplicas:    10,
+			},
+		}
+	}
+	tests := []struct {
+		name         string
+		hpa          *autoscalingv2.HorizontalPodAutoscaler
+		owners       []metav1.OwnerReference
+		wantReplicas int32
+		wantImage    string
+	}{
+		{name: "replicas follow the spec", owners: controlledBy(app), wantReplicas: 2, wantImage: "demo:v1-new"},
+		{
+			name:         "replicas of an autoscaled Deployment are kept",
+			hpa:          hpa("Deployment", name),
+			owners:       controlledBy(app),
+			wantReplicas: 7,
+			wantImage:    "demo:v1-new",
+		},
+		{
+			name:         "autoscaler of another Deployment",
+			hpa:          hpa("Deployment", "other"),
+			owners:       controlledBy(app),
+			wantReplicas: 2,
+			wantImage:    "demo:v1-new",
+		},
+		{
+			name:         "autoscaler of another kind",
+			hpa:          hpa("StatefulSet", name),
+			owners:       controlledBy(app),
+			wantReplicas: 2,
+			wantImage:    "demo:v1-new",
+		},
+		{name: "foreign Deployment left alone", wantReplicas: 7, wantImage: "demo:v1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := func(replicas int32, image string) *appsv1.Deployment {
+				return &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: app.Namespace, OwnerReferences: tt.owners},
+					Spec: appsv1.DeploymentSpec{
+						Replicas: ptr.To(replicas),
+						Template: corev1.PodTemplateSpec{
+							Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "demo", Image: image}}},
+						},
+					},
+				}
+			}
+			objs := []client.Object{deployment(7, "demo:v1")}
+			if tt.hpa != nil {
+				objs = append(objs, tt.hpa)
+			}
+			r := newTestReconciler(t, objs...)
+
+			if err := r.updateVersion(context.Background(), app, deployment(2, "demo:v1-new")); err != nil {
+				t.Fatalf("updateVersion() error = %v", err)
+			}
+			got := &appsv1.Deployment{}
+			if err := r.Get(context.Background(), client.ObjectKey{Namespace: app.Namespace, Name: name}, got); err != nil {
+				t.Fatal(err)
+			}
+			if *got.Spec.Replicas != tt.wantReplicas {
+				t.Errorf("replicas = %d, want %d", *got.Spec.Replicas, tt.wantReplicas)
+			}
+			if image := got.Spec.Template.Spec.Containers[0].Image; image != tt.wantImage {
+				t.Errorf("image = %s, want %s", image, tt.wantImage)
+			}
+		})
+	}
+}
@@ -2,16 +2,29 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"slices"
 	"sort"
 	"strconv"
+	"time"
 
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+const (
+	// retireAtAnnotation holds the time a pruned Deployment is deleted after draining
+	retireAtAnnotation  = "apps.api.test/retire-at"
+	defaultDrainSeconds = 30
+	// pruneRequeue is how soon pruning continues when it was capped or blocked
+	pruneRequeue = 10 * time.Second
+)
+
 // sortVersions sorts the version strings (assuming formats like "v21", "v22") // so with 2 works and keeps them by timestamp
 
 func sortDeploymentsByTimestamp(deployments []appsv1.Deployment) []appsv1.Deployment {
@@ -61,18 +74,170 @@ func extractLatestVersions(deployments []appsv1.Deployment) []string {
 	return latestVersions
 }
 
-// cleanupOldDeployments removes older deployments beyond the latest two.
+// selectVersions returns the routed versions, picked by the version policy among the routable
+// ones, and the versions to retain, which also hold the spec version while it is not routable
+// yet so the previous route set stays in place
+func selectVersions(
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+	deployments []appsv1.Deployment,
+	specRoutable bool,
+) ([]string, []string) {
+	specVersion := SimpleAPIApp.Spec.Version
+	routable := []appsv1.Deployment{}
+	for _, dep := range deployments {
+		if dep.Labels["version"] == specVersion && !specRoutable {
+			continue
+		}
+		routable = append(routable, dep)
+	}
+	routed := routedVersions(SimpleAPIApp, routable)
+
+	retained := append([]string{}, routed...)
+	if !slices.Contains(retained, specVersion) {
+		retained = append(retained, specVersion)
+	}
+	return routed, retained
+}
+
+// deploymentAvailable reports whether the Deployment has the Available condition and
+// at least minReadyReplicas ready pods
+func deploymentAvailable(dep *appsv1.Deployment, minReadyReplicas int32) bool {
+	for _, cond := range dep.Status.Conditions {
+		if cond.Type == appsv1.DeploymentAvailable && cond.Status == corev1.ConditionTrue {
+			return dep.Status.ReadyReplicas >= minReadyReplicas
+		}
+	}
+	return false
+}
+
+func minReadyReplicas(SimpleAPIApp *appsv1alpha1.Simpleapi) int32 {
+	if SimpleAPIApp.Spec.MinReadyReplicas != nil {
+		return *SimpleAPIApp.Spec.MinReadyReplicas
+	}
+	return 1
+}
+
+// deploymentFailed reports whether the Deployment exceeded its progress deadline
+func deploymentFailed(dep *appsv1.Deployment) bool {
+	for _, cond := range dep.Status.Conditions {
+		if cond.Type == appsv1.DeploymentProgressing && cond.Status == corev1.ConditionFalse {
+			return cond.Reason == "ProgressDeadlineExceeded"
+		}
+	}
+	return false
+}
+
+// specVersionFailed reports whether the rollout of the spec version failed
+func specVersionFailed(SimpleAPIApp *appsv1alpha1.Simpleapi, deployments []appsv1.Deployment) bool {
+	for i := range deployments {
+		if deployments[i].Labels["version"] == SimpleAPIApp.Spec.Version {
+			return deploymentFailed(&deployments[i])
+		}
+	}
+	return false
+}
+
+// specVersionAvailable reports whether the Deployment of the spec version is available
+func specVersionAvailable(SimpleAPIApp *appsv1alpha1.Simpleapi, deployments []appsv1.Deployment) bool {
+	for i := range deployments {
+		if deployments[i].Labels["version"] == SimpleAPIApp.Spec.Version {
+			return deploymentAvailable(&deployments[i], minReadyReplicas(SimpleAPIApp))
+		}
+	}
+	return false
+}
+
+// cleanupOldDeployments removes the deployments, and their services, of versions that are not retained.
+// A pruned version is first annotated with its retire time and deleted once the drain period
+// has elapsed, the returned duration is when the next one is due.
+// Versions still referenced by the live route and objects not controlled by the Simpleapi are
+// never deleted, at most maxPrunesPerReconcile versions are deleted per call.
 func (r *SimpleapiReconciler) cleanupOldDeployments(
 	ctx context.Context,
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
 	deployments []appsv1.Deployment,
-) {
+	retained []string,
+	now time.Time,
+) (time.Duration, metav1.Condition, error) {
 	logger := log.FromContext(ctx)
-	if len(deployments) <= 2 {
-		return
+	keep := map[string]bool{}
+	for _, ver := range retained {
+		keep[ver] = true
+	}
+	routed, err := r.liveRouteServices(ctx, SimpleAPIApp)
+	if err != nil {
+		return 0, metav1.Condition{}, err
 	}
-	oldDeployments := deployments[:len(deployments)-2]
-	for _, oldDep := range oldDeployments {
+
+	var requeueAfter time.Duration
+	failures := []string{}
+	pruned := 0
+	for _, oldDep := range deployments {
 		depToDelete := oldDep // Use a new variable for the loop to avoid issues with pointers in loops if not careful
+		version := depToDelete.Labels["version"]
+		retireAt, retiring := depToDelete.Annotations[retireAtAnnotation]
+
+		if keep[version] {
+			// the version is retained again, for example after a rollback
+			if retiring {
+				patch := client.MergeFrom(depToDelete.DeepCopy())
+				delete(depToDelete.Annotations, retireAtAnnotation)
+				if err := r.Patch(ctx, &depToDelete, patch); err != nil {
+					logger.Error(err, "Failed to clear retire time", "deployment", depToDelete.Name)
+					failures = append(failures, fmt.Sprintf("%s: %v", version, err))
+				}
+			}
+			continue
+		}
+		if !metav1.IsControlledBy(&depToDelete, SimpleAPIApp) {
+			logger.Info("Refusing to prune deployment not managed by this Simpleapi", "deployment", depToDelete.Name)
+			failures = append(failures, fmt.Sprintf("%s: deployment %s is not owned", version, depToDelete.Name))
+			continue
+		}
+
+		if !retiring {
+			retireAt = now.Add(drainPeriod(SimpleAPIApp)).UTC().Format(time.RFC3339)
+			patch := client.MergeFrom(depToDelete.DeepCopy())
+			if depToDelete.Annotations == nil {
+				depToDelete.Annotations = map[string]string{}
+			}
+			depToDelete.Annotations[retireAtAnnotation] = retireAt
+			if err := r.Patch(ctx, &depToDelete, patch); err != nil {
+				logger.Error(err, "Failed to set retire time", "deployment", depToDelete.Name)
+				failures = append(failures, fmt.Sprintf("%s: %v", version, err))
+				continue
+			}
+			logger.Info("Draining old deployment", "deployment", depToDelete.Name, "retireAt", retireAt)
+		}
+		if t, err := time.Parse(time.RFC3339, retireAt); err == nil && now.Before(t) {
+			requeueAfter = minRequeue(requeueAfter, t.Sub(now))
+			continue
+		}
+
+		oldServiceName := serviceNameFromDeploymentName(depToDelete.Name)
+		if routed[oldServiceName] {
+			logger.Info("Old deployment is still routed, not deleting it", "deployment", depToDelete.Name)
+			failures = append(failures, fmt.Sprintf("%s: still referenced by the route", version))
+			requeueAfter = minRequeue(requeueAfter, pruneRequeue)
+			continue
+		}
+		if pruned >= maxPrunesPerReconcile {
+			requeueAfter = minRequeue(requeueAfter, pruneRequeue)
+			continue
+		}
+		pruned++
+
+		oldSvc := &corev1.Service{}
+		err := r.Get(ctx, client.ObjectKey{Namespace: depToDelete.Namespace, Name: oldServiceName}, oldSvc)
+		if err == nil && !metav1.IsControlledBy(oldSvc, SimpleAPIApp) {
+			logger.Info("Refusing to prune service not managed by this Simpleapi", "service", oldServiceName)
+			failures = append(failures, fmt.Sprintf("%s: service %s is not owned", version, oldServiceName))
+			continue
+		} else if err != nil && !apierrors.IsNotFound(err) {
+			failures = append(failures, fmt.Sprintf("%s: %v", version, err))
+			continue
+		}
+
 		logger.Info(
 			"Deleting old deployment",
 			"deployment",
@@ -80,31 +245,45 @@ func (r *SimpleapiReconciler) cleanupOldDeployments(
 			"namespace",
 			depToDelete.Namespace,
 		)
-
-		if err := r.Delete(ctx, &depToDelete); err != nil && !apierrors.IsNotFound(err) {
+		// the UID precondition keeps a recreated object with the same name safe
+		if err := r.Delete(ctx, &depToDelete, client.Preconditions{UID: &depToDelete.UID}); err != nil &&
+			!apierrors.IsNotFound(err) {
 			logger.Error(err, "Failed to delete old deployment", "deployment", depToDelete.Name)
-			// maybe to see how to return error as improvement
+			failures = append(failures, fmt.Sprintf("%s: %v", version, err))
+			continue
 		}
 
-		oldServiceName := serviceNameFromDeploymentName(depToDelete.Name)
-		oldSvc := &corev1.Service{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      oldServiceName,
-				Namespace: depToDelete.Namespace,
-			},
+		if err == nil {
+			logger.Info(
+				"Attempting to delete old service",
+				"service",
+				oldServiceName,
+				"namespace",
+				depToDelete.Namespace,
+			)
+			if err := r.Delete(ctx, oldSvc, client.Preconditions{UID: &oldSvc.UID}); err != nil &&
+				!apierrors.IsNotFound(err) {
+				logger.Error(err, "Failed to delete old service", "service", oldServiceName)
+				failures = append(failures, fmt.Sprintf("%s: %v", version, err))
+			}
 		}
-		logger.Info(
-			"Attempting to delete old service",
-			"service",
-			oldServiceName,
-			"namespace",
-			depToDelete.Namespace,
-		)
+	}
+	return requeueAfter, pruneCondition(SimpleAPIApp, failures), nil
+}
 
-		if err := r.Delete(ctx, oldSvc); err != nil && !apierrors.IsNotFound(err) {
-			logger.Error(err, "Failed to delete old service", "service", oldServiceName)
-		}
+func drainPeriod(SimpleAPIApp *appsv1alpha1.Simpleapi) time.Duration {
+	if SimpleAPIApp.Spec.DrainSeconds != nil {
+		return time.Duration(*SimpleAPIApp.Spec.DrainSeconds) * time.Second
+	}
+	return defaultDrainSeconds * time.Second
+}
+
+// minRequeue returns the earlier of two requeue delays, zero means no requeue
+func minRequeue(a, b time.Duration) time.Duration {
+	if a == 0 || (b > 0 && b < a) {
+		return b
 	}
+	return a
 }
 
 func serviceNameFromDeploymentName(deploymentName string) string {
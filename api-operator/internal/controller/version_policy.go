@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+)
+
+const (
+	orderingDeployedAt string = "deployedAt"
+	orderingSemver     string = "semver"
+
+	// maxNameLength is the DNS label limit that Service and Job names must fit in
+	maxNameLength = 63
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// parseVersion parses v1.2.3, v1.2 and v1 style versions, pre-releases are allowed on full
+// semantic versions. Build metadata parses too but the CRD rejects it, + is not allowed in
+// the version label.
+func parseVersion(version string) (*utilversion.Version, error) {
+	if v, err := utilversion.ParseSemantic(version); err == nil {
+		return v, nil
+	}
+	if !strings.Contains(version, ".") {
+		version += ".0"
+	}
+	return utilversion.ParseGeneric(version)
+}
+
+func semverOrdering(SimpleAPIApp *appsv1alpha1.Simpleapi) bool {
+	policy := SimpleAPIApp.Spec.VersionPolicy
+	return policy != nil && (policy.Ordering == orderingSemver || policy.KeepMajors != nil)
+}
+
+// sortDeployments orders the deployments oldest first, by lastDeployedAt or, with the semver
+// ordering, by version where versions that do not parse come first in deployment order
+func sortDeployments(
+	SimpleAPIApp *appsv1alpha1.Simpleapi,
+	deployments []appsv1.Deployment,
+) []appsv1.Deployment {
+	deployments = sortDeploymentsByTimestamp(deployments)
+	if !semverOrdering(SimpleAPIApp) {
+		return deployments
+	}
+	sort.SliceStable(deployments, func(i, j int) bool {
+		vi, errI := parseVersion(deployments[i].Labels["version"])
+		vj, errJ := parseVersion(deployments[j].Labels["version"])
+		switch {
+		case errI != nil || errJ != nil:
+			return errI != nil && errJ == nil
+		default:
+			return vi.LessThan(vj)
+		}
+	})
+	return deployments
+}
+
+// routedVersions returns the newest two versions, or with keepMajors the newest version of
+// each of the last N major versions, oldest first
+func routedVersions(SimpleAPIApp *appsv1alpha1.Simpleapi, deployments []appsv1.Deployment) []string {
+	policy := SimpleAPIApp.Spec.VersionPolicy
+	if policy == nil || policy.KeepMajors == nil {
+		return extractLatestVersions(deployments)
+	}
+
+	versions := []string{}
+	majors := map[uint]bool{}
+	for i := len(deployments) - 1; i >= 0 && len(majors) < int(*policy.KeepMajors); i-- {
+		version := deployments[i].Labels["version"]
+		v, err := parseVersion(version)
+		if err != nil || majors[v.Major()] {
+			continue
+		}
+		majors[v.Major()] = true
+		versions = append([]string{version}, versions...)
+	}
+	return versions
+}
+
+// versionPath is the route path of a version, /api/v<major> when minor releases replace
+// each other, /api/<version> otherwise
+func versionPath(SimpleAPIApp *appsv1alpha1.Simpleapi, version string) string {
+	if SimpleAPIApp.Spec.VersionPolicy != nil && SimpleAPIApp.Spec.VersionPolicy.KeepMajors != nil {
+		if v, err := parseVersion(version); err == nil {
+			return fmt.Sprintf("/api/v%d", v.Major())
+		}
+	}
+	return "/api/" + version
+}
+
+// versionNameCondition reports whether the Deployment name of the spec version is taken by
+// another version, dnsSafeName maps versions that only differ in case or in the characters
+// it replaces to the same name
+func versionNameCondition(SimpleAPIApp *appsv1alpha1.Simpleapi, deployments []appsv1.Deployment) metav1.Condition {
+	name := deploymentName(SimpleAPIApp.Spec.Version, SimpleAPIApp.Name)
+	for _, dep := range deployments {
+		if dep.Name == name && dep.Labels["version"] != SimpleAPIApp.Spec.Version {
+			return metav1.Condition{
+				Type:   appsv1alpha1.ConditionVersionNameUnique,
+				Status: metav1.ConditionFalse,
+				Reason: "NameConflict",
+				Message: fmt.Sprintf(
+					"version %s maps to Deployment %s of version %s, pick a version that differs in more than case or punctuation",
+					SimpleAPIApp.Spec.Version,
+					name,
+					dep.Labels["version"],
+				),
+				ObservedGeneration: SimpleAPIApp.Generation,
+			}
+		}
+	}
+	return metav1.Condition{
+		Type:               appsv1alpha1.ConditionVersionNameUnique,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Unique",
+		Message:            fmt.Sprintf("version %s has its own Deployment %s", SimpleAPIApp.Spec.Version, name),
+		ObservedGeneration: SimpleAPIApp.Generation,
+	}
+}
+
+// dnsSafeName lowercases the name, replaces characters that are not allowed in a DNS label
+// and, when it is longer than maxLen, shortens it with a hash of the full name
+func dnsSafeName(name string, maxLen int) string {
+	name = strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(name) <= maxLen {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	return strings.TrimRight(name[:maxLen-9], "-") + "-" + hex.EncodeToString(sum[:])[:8]
+}
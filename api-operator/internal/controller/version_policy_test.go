@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func versionDeployments(appName string, versions ...string) []appsv1.Deployment {
+	deployments := make([]appsv1.Deployment, len(versions))
+	for i, version := range versions {
+		deployments[i] = appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   deploymentName(version, appName),
+				Labels: map[string]string{"app": appName, "version": version},
+			},
+		}
+	}
+	return deployments
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+		wantErr bool
+	}{
+		{"v1.2.3", "1.2.3", false},
+		{"1.2.3", "1.2.3", false},
+		{"v1.0.0-rc.1", "1.0.0-rc.1", false},
+		{"v2.1", "2.1", false},
+		{"v3", "3.0", false},
+		{"latest", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := parseVersion(tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("parseVersion() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSSafeName(t *testing.T) {
+	long := "demo-" + strings.Repeat("v1.2.3-build", 8)
+	tests := []struct {
+		name   string
+		input  string
+		maxLen int
+		want   string
+	}{
+		{"already safe", "demo-v1", maxNameLength, "demo-v1"},
+		{"lowercased", "Demo-V1", maxNameLength, "demo-v1"},
+		{"dots and underscores replaced", "demo-v1.2_3", maxNameLength, "demo-v1-2-3"},
+		{"runs of invalid characters collapsed", "demo-v1+..build", maxNameLength, "demo-v1-build"},
+		{"leading and trailing dashes trimmed", "-demo-v1.", maxNameLength, "demo-v1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dnsSafeName(tt.input, tt.maxLen); got != tt.want {
+				t.Errorf("dnsSafeName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("long names are shortened with a hash", func(t *testing.T) {
+		maxLen := maxNameLength - len("-svc")
+		got := dnsSafeName(long, maxLen)
+		if len(got) > maxLen {
+			t.Errorf("dnsSafeName() = %q is longer than %d", got, maxLen)
+		}
+		if got != dnsSafeName(long, maxLen) {
+			t.Errorf("dnsSafeName() is not stable")
+		}
+		if other := dnsSafeName(long+"-2", maxLen); other == got {
+			t.Errorf("dnsSafeName() = %q for two different names", got)
+		}
+	})
+}
+
+func TestRoutedVersions(t *testing.T) {
+	tests := []struct {
+		name       string
+		keepMajors *int32
+		versions   []string
+		want       []string
+	}{
+		{"latest two", nil, []string{"v1.0.0", "v1.1.0", "v2.0.0"}, []string{"v1.1.0", "v2.0.0"}},
+		{"single version", nil, []string{"v1.0.0"}, []string{"v1.0.0"}},
+		{
+			"newest of the last two majors",
+			ptr.To[int32](2),
+			[]string{"v1.0.0", "v1.1.0", "v2.0.0", "v2.1.0"},
+			[]string{"v1.1.0", "v2.1.0"},
+		},
+		{
+			"newest of the last major",
+			ptr.To[int32](1),
+			[]string{"v1.0.0", "v2.0.0", "v2.1.0"},
+			[]string{"v2.1.0"},
+		},
+		{
+			"older majors beyond keepMajors dropped",
+			ptr.To[int32](2),
+			[]string{"v1.0.0", "v2.0.0", "v3.0.0"},
+			[]string{"v2.0.0", "v3.0.0"},
+		},
+		{
+			"versions that do not parse are skipped",
+			ptr.To[int32](2),
+			[]string{"v1.0.0", "latest", "v2.0.0"},
+			[]string{"v1.0.0", "v2.0.0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &appsv1alpha1.Simpleapi{}
+			if tt.keepMajors != nil {
+				app.Spec.VersionPolicy = &appsv1alpha1.VersionPolicySpec{KeepMajors: tt.keepMajors}
+			}
+			got := routedVersions(app, versionDeployments("demo", tt.versions...))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("routedVersions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionNameCondition(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		existing []string
+		want     metav1.ConditionStatus
+	}{
+		{"no deployments", "v1", nil, metav1.ConditionTrue},
+		{"same version", "v1", []string{"v1"}, metav1.ConditionTrue},
+		{"other versions", "v2", []string{"v1"}, metav1.ConditionTrue},
+		{"differs in case", "V1", []string{"v1"}, metav1.ConditionFalse},
+		{"differs in punctuation", "v1_2", []string{"v1.2"}, metav1.ConditionFalse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &appsv1alpha1.Simpleapi{
+				ObjectMeta: metav1.ObjectMeta{Name: "demo"},
+				Spec:       appsv1alpha1.SimpleapiSpec{Version: tt.version},
+			}
+			got := versionNameCondition(app, versionDeployments("demo", tt.existing...))
+			if got.Status != tt.want {
+				t.Errorf("versionNameCondition() = %s (%s), want %s", got.Status, got.Message, tt.want)
+			}
+		})
+	}
+}
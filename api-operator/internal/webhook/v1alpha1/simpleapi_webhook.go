@@ -0,0 +1,217 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1alpha1 holds the admission webhooks of the apps v1alpha1 API
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1alpha1 "github.com/dkr290/simple-operator/api-operator/api/v1alpha1"
+	"github.com/dkr290/simple-operator/api-operator/internal/controller"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var simpleapilog = logf.Log.WithName("simpleapi-resource")
+
+// SetupSimpleapiWebhookWithManager registers the webhook for Simpleapi in the manager.
+func SetupSimpleapiWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&appsv1alpha1.Simpleapi{}).
+		WithValidator(&SimpleapiCustomValidator{Client: mgr.GetClient()}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-apps-api-test-v1alpha1-simpleapi,mutating=false,failurePolicy=fail,sideEffects=None,groups=apps.api.test,resources=simpleapis,verbs=create;update,versions=v1alpha1,name=vsimpleapi-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// SimpleapiCustomValidator validates the podTemplatePatch by dry-run creating the Deployment it
+// produces, so the API server checks the patched pod template before the Simpleapi is stored.
+// It also checks that the requesting user holds the rbacRules the operator grants on their behalf.
+type SimpleapiCustomValidator struct {
+	Client client.Client
+}
+
+var _ admission.CustomValidator = &SimpleapiCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type Simpleapi.
+func (v *SimpleapiCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	simpleapi, ok := obj.(*appsv1alpha1.Simpleapi)
+	if !ok {
+		return nil, fmt.Errorf("expected a Simpleapi object but got %T", obj)
+	}
+	simpleapilog.Info("Validation for Simpleapi upon creation", "name", simpleapi.GetName())
+	if err := v.validateRBACRules(ctx, simpleapi); err != nil {
+		return nil, err
+	}
+	return nil, v.validatePodTemplatePatch(ctx, simpleapi)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type Simpleapi.
+func (v *SimpleapiCustomValidator) ValidateUpdate(
+	ctx context.Context,
+	oldObj, newObj runtime.Object,
+) (admission.Warnings, error) {
+	simpleapi, ok := newObj.(*appsv1alpha1.Simpleapi)
+	if !ok {
+		return nil, fmt.Errorf("expected a Simpleapi object for the newObj but got %T", newObj)
+	}
+	oldSimpleapi, ok := oldObj.(*appsv1alpha1.Simpleapi)
+	if !ok {
+		return nil, fmt.Errorf("expected a Simpleapi object for the oldObj but got %T", oldObj)
+	}
+	simpleapilog.Info("Validation for Simpleapi upon update", "name", simpleapi.GetName())
+	// unchanged rules were checked when they were added, other users may still edit the Simpleapi
+	if !equality.Semantic.DeepEqual(oldSimpleapi.Spec.RBACRules, simpleapi.Spec.RBACRules) {
+		if err := v.validateRBACRules(ctx, simpleapi); err != nil {
+			return nil, err
+		}
+	}
+	return nil, v.validatePodTemplatePatch(ctx, simpleapi)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type Simpleapi.
+func (v *SimpleapiCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *SimpleapiCustomValidator) validatePodTemplatePatch(
+	ctx context.Context,
+	simpleapi *appsv1alpha1.Simpleapi,
+) error {
+	if simpleapi.Spec.PodTemplatePatch == nil {
+		return nil
+	}
+	deployment, err := controller.VersionDeployment(simpleapi)
+	if err != nil {
+		return err
+	}
+	// a generated name keeps the dry run clear of the existing version Deployment
+	deployment.GenerateName = deployment.Name + "-"
+	deployment.Name = ""
+	deployment.Namespace = simpleapi.Namespace
+	if err := v.Client.Create(ctx, deployment, client.DryRunAll); err != nil {
+		return fmt.Errorf("podTemplatePatch produces an invalid Deployment: %w", err)
+	}
+	return nil
+}
+
+// validateRBACRules rejects rbacRules the requesting user could not grant with a Role of their own,
+// so the operator never becomes a way to escalate privileges in the namespace
+func (v *SimpleapiCustomValidator) validateRBACRules(
+	ctx context.Context,
+	simpleapi *appsv1alpha1.Simpleapi,
+) error {
+	if len(simpleapi.Spec.RBACRules) == 0 {
+		return nil
+	}
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	denied := []string{}
+	for _, rule := range simpleapi.Spec.RBACRules {
+		if len(rule.NonResourceURLs) > 0 {
+			denied = append(denied, "nonResourceURLs cannot be granted by a Role")
+			continue
+		}
+		resourceNames := rule.ResourceNames
+		if len(resourceNames) == 0 {
+			resourceNames = []string{""}
+		}
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				resource, subresource, _ := strings.Cut(resource, "/")
+				for _, verb := range rule.Verbs {
+					for _, name := range resourceNames {
+						attributes := &authorizationv1.ResourceAttributes{
+							Namespace:   simpleapi.Namespace,
+							Verb:        verb,
+							Group:       group,
+							Resource:    resource,
+							Subresource: subresource,
+							Name:        name,
+						}
+						allowed, err := v.userAllowed(ctx, req.UserInfo, attributes)
+						if err != nil {
+							return err
+						}
+						if !allowed {
+							denied = append(denied, fmt.Sprintf("%s %s", verb, ruleResource(attributes)))
+						}
+					}
+				}
+			}
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf(
+			"rbacRules grant permissions user %s does not hold in namespace %s: %s",
+			req.UserInfo.Username,
+			simpleapi.Namespace,
+			strings.Join(denied, ", "),
+		)
+	}
+	return nil
+}
+
+func (v *SimpleapiCustomValidator) userAllowed(
+	ctx context.Context,
+	user authenticationv1.UserInfo,
+	attributes *authorizationv1.ResourceAttributes,
+) (bool, error) {
+	extra := map[string]authorizationv1.ExtraValue{}
+	for k, values := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(values)
+	}
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: attributes,
+			User:               user.Username,
+			Groups:             user.Groups,
+			UID:                user.UID,
+			Extra:              extra,
+		},
+	}
+	if err := v.Client.Create(ctx, review); err != nil {
+		return false, err
+	}
+	return review.Status.Allowed, nil
+}
+
+func ruleResource(attributes *authorizationv1.ResourceAttributes) string {
+	resource := attributes.Resource
+	if attributes.Group != "" {
+		resource += "." + attributes.Group
+	}
+	if attributes.Subresource != "" {
+		resource += "/" + attributes.Subresource
+	}
+	if attributes.Name != "" {
+		resource += " " + attributes.Name
+	}
+	return resource
+}